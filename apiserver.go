@@ -1,10 +1,14 @@
 package gotana
 
 import (
+	"errors"
+	"fmt"
 	fury "github.com/jnosal/gofury"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type HealthCheckResource struct {
@@ -32,12 +36,43 @@ func (resource StatsResource) Get(meta *fury.Meta) {
 		info["succesful"] = stats.successful
 		info["scraped"] = stats.scraped
 		info["saved"] = stats.saved
+		info["noindex"] = stats.noindex
+		info["nofollow"] = stats.nofollow
+		info["duplicates"] = stats.duplicates
+		info["depths"] = resource.engine.Meta.DepthHistogram(scraper)
+		info["pipeline"] = resource.engine.Meta.PipelineStats(scraper)
+		info["validationErrors"] = resource.engine.Meta.ValidationErrorCounts(scraper)
 		result[scraper.Name] = info
 	}
 
 	meta.Json(http.StatusOK, result)
 }
 
+type ComponentsResource struct {
+	engine *Engine
+}
+
+func (resource ComponentsResource) Get(meta *fury.Meta) {
+	result := DescribeComponents(resource.engine.Registry(), resource.engine.Active)
+	meta.Json(http.StatusOK, result)
+}
+
+type QualityResource struct {
+	engine *Engine
+}
+
+func (resource QualityResource) Get(meta *fury.Meta) {
+	result := map[string]QualityReport{}
+	for _, extension := range resource.engine.extensions {
+		if reporter, ok := extension.(QualityReporter); ok {
+			for name, report := range reporter.QualityReports() {
+				result[name] = report
+			}
+		}
+	}
+	meta.Json(http.StatusOK, result)
+}
+
 type ListByScraperResource struct {
 	engine *Engine
 }
@@ -54,14 +89,77 @@ func (resource ListByScraperResource) Get(meta *fury.Meta) {
 	}
 
 	dao := GetDAO(resource.engine)
-	items := dao.GetItems(scraper.Name)
+	if dao == nil {
+		result["error"] = "Storage is not configured."
+		meta.Json(http.StatusServiceUnavailable, result)
+		return
+	}
+
+	if id := meta.Query().Get("id"); id != "" {
+		item, ok := dao.GetItem(scraper.Name, id)
+		if !ok {
+			result["error"] = "Item does not exist."
+			meta.Json(http.StatusNotFound, result)
+			return
+		}
+		result["item"] = storedItemData(dao, item)
+		meta.Json(http.StatusOK, result)
+		return
+	}
+
+	query, err := parseItemQuery(meta.Query())
+	if err != nil {
+		result["error"] = err.Error()
+		meta.Json(http.StatusBadRequest, result)
+		return
+	}
+
+	items := []genericStruct{}
+	for _, item := range dao.ListItems(scraper.Name, query) {
+		items = append(items, storedItemData(dao, item))
+	}
+
+	total := dao.CountItems(scraper.Name)
+	count := total
+	if !query.Since.IsZero() {
+		count = dao.CountItemsSince(scraper.Name, query.Since)
+	}
 
-	result["items"] = dao.ProcessItems(items)
-	result["count"] = dao.CountItems(scraper.Name)
+	result["items"] = items
+	result["count"] = count
+	result["total"] = total
+	result["limit"] = query.Limit
+	result["offset"] = query.Offset
 
 	meta.Json(http.StatusOK, result)
 }
 
+func storedItemData(dao DAO, item StoredItem) genericStruct {
+	data := dao.ProcessItem(item.Data)
+	data["_id"] = item.ID
+	data["_saved"] = item.Saved.UTC().Format(time.RFC3339Nano)
+	return data
+}
+
+func parseItemQuery(values url.Values) (query ItemQuery, err error) {
+	for name, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if value := values.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				return query, errors.New(fmt.Sprintf("Invalid %s: %s", name, value))
+			}
+		}
+	}
+
+	if value := values.Get("since"); value != "" {
+		if seconds, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil {
+			query.Since = time.Unix(seconds, 0)
+		} else if query.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, errors.New(fmt.Sprintf("Invalid since: %s", value))
+		}
+	}
+	return
+}
+
 func NewHTTPServer(address string, engine *Engine) (server *fury.Fury) {
 	chunks := strings.Split(address, ":")
 	host := chunks[0]
@@ -71,5 +169,7 @@ func NewHTTPServer(address string, engine *Engine) (server *fury.Fury) {
 	server.Route("/api/healthcheck", &HealthCheckResource{engine})
 	server.Route("/api/items", &ListByScraperResource{engine})
 	server.Route("/api/stats", &StatsResource{engine})
+	server.Route("/api/components", &ComponentsResource{engine})
+	server.Route("/api/quality", &QualityResource{engine})
 	return
 }
@@ -1,20 +1,65 @@
 package gotana
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-redis/redis"
+	bolt "go.etcd.io/bbolt"
+	URL "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STORAGE_REDIS  = "redis"
+	STORAGE_BOLT   = "bolt"
+	STORAGE_MEMORY = "memory"
+	RUNS_NAMESPACE = "gotana:runs"
 )
 
 type genericStruct map[string]interface{}
 
+type StoredItem struct {
+	ID    string
+	Saved time.Time
+	Data  string
+}
+
+type ItemQuery struct {
+	Offset int
+	Limit  int
+	Since  time.Time
+}
+
 type DAO interface {
 	SaveItem(name string, data []byte) error
 	GetItems(name string) []string
 	CountItems(name string) int64
+	CountItemsSince(name string, since time.Time) int64
+	ListItems(name string, query ItemQuery) []StoredItem
+	GetItem(name string, id string) (StoredItem, bool)
+	LatestItems(name string, count int) []StoredItem
+	DeleteItem(name string, id string) (bool, error)
+	PurgeItems(name string) error
+	PurgeOlderThan(name string, age time.Duration) (int64, error)
 	ProcessItem(items string) genericStruct
 	ProcessItems(items []string) []genericStruct
 }
 
+type ExpiringDAO interface {
+	WithExpiry(ttl time.Duration) DAO
+}
+
+type RunStore interface {
+	SaveRun(scraper string, data []byte) error
+	LatestRuns(scraper string, count int) ([]string, error)
+}
+
 func SaveItem(item SaveableItem, dao DAO) {
 	if dao == nil {
 		return
@@ -31,49 +76,341 @@ func SaveItem(item SaveableItem, dao DAO) {
 	}
 }
 
+func processItem(item string) genericStruct {
+	var data = genericStruct{}
+	json.Unmarshal([]byte(item), &data)
+	return data
+}
+
+func processItems(items []string) []genericStruct {
+	result := make([]genericStruct, len(items))
+
+	for index, item := range items {
+		result[index] = processItem(item)
+	}
+
+	return result
+}
+
+func storedData(items []StoredItem) []string {
+	result := make([]string, len(items))
+	for index, item := range items {
+		result[index] = item.Data
+	}
+	return result
+}
+
+func effectiveSince(since time.Time, ttl time.Duration) time.Time {
+	if ttl > 0 {
+		if expired := time.Now().Add(-ttl); expired.After(since) {
+			return expired
+		}
+	}
+	return since
+}
+
+func embeddedTime(data string, field string) (saved time.Time, ok bool) {
+	record := map[string]interface{}{}
+	if field == "" || json.Unmarshal([]byte(data), &record) != nil {
+		return
+	}
+	switch value := record[field].(type) {
+	case float64:
+		return time.Unix(int64(value), 0), true
+	case string:
+		if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(seconds, 0), true
+		}
+		saved, err := time.Parse(time.RFC3339Nano, value)
+		return saved, err == nil
+	}
+	return
+}
+
+func pageItems(items []StoredItem, query ItemQuery) []StoredItem {
+	if query.Offset > 0 {
+		if query.Offset >= len(items) {
+			return []StoredItem{}
+		}
+		items = items[query.Offset:]
+	}
+	if query.Limit > 0 && len(items) > query.Limit {
+		items = items[:query.Limit]
+	}
+	return items
+}
+
 type RedisDAO struct {
-	client *redis.Client
+	client          *redis.Client
+	ttl             time.Duration
+	legacyTimeField string
+	migrated        *sync.Map
 }
 
 func (r RedisDAO) KeyPrefixed(key string) string {
 	return "gotana-" + key
 }
 
+func (r RedisDAO) keys(name string) (index string, data string, sequence string) {
+	r.migrate(name)
+	index = r.KeyPrefixed("items-" + name)
+	return index, index + "-data", index + "-seq"
+}
+
+func (r RedisDAO) migrate(name string) {
+	if _, done := r.migrated.LoadOrStore(name, true); done {
+		return
+	}
+
+	legacy := r.KeyPrefixed(name)
+	if r.client.Type(legacy).Val() != "set" {
+		return
+	}
+	members, err := r.client.SMembers(legacy).Result()
+	if err != nil {
+		Logger().Warningf("Cannot migrate items of %s: %s", name, err)
+		r.migrated.Delete(name)
+		return
+	}
+
+	migrated := time.Now()
+	times := make(map[string]time.Time, len(members))
+	for _, data := range members {
+		if saved, ok := embeddedTime(data, r.legacyTimeField); ok {
+			times[data] = saved
+		} else {
+			times[data] = migrated
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if !times[members[i]].Equal(times[members[j]]) {
+			return times[members[i]].Before(times[members[j]])
+		}
+		return members[i] < members[j]
+	})
+
+	for _, data := range members {
+		if err = r.saveItem(name, []byte(data), times[data]); err == nil {
+			err = r.client.SRem(legacy, data).Err()
+		}
+		if err != nil {
+			Logger().Warningf("Cannot migrate items of %s: %s", name, err)
+			r.migrated.Delete(name)
+			return
+		}
+	}
+	Logger().Infof("Migrated %d items of %s from %s", len(members), name, legacy)
+}
+
+func (r RedisDAO) member(id string) string {
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%020d", number)
+}
+
+func (r RedisDAO) score(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Microsecond), 10)
+}
+
+func (r RedisDAO) minScore(since time.Time) string {
+	since = effectiveSince(since, r.ttl)
+	if since.IsZero() {
+		return "-inf"
+	}
+	return r.score(since)
+}
+
+func (r RedisDAO) WithTTL(ttl time.Duration) RedisDAO {
+	r.ttl = ttl
+	return r
+}
+
+func (r RedisDAO) WithExpiry(ttl time.Duration) DAO {
+	return r.WithTTL(ttl)
+}
+
+func (r RedisDAO) WithLegacyTimeField(field string) RedisDAO {
+	r.legacyTimeField = field
+	return r
+}
+
 func (r RedisDAO) SaveItem(name string, data []byte) error {
-	stringData := string(data[:])
-	key := r.KeyPrefixed(name)
-	r.client.SAdd(key, stringData)
-	return nil
+	return r.saveItem(name, data, time.Now())
 }
 
-func (r RedisDAO) GetLatestItem(name string) error {
-	return nil
+func (r RedisDAO) saveItem(name string, data []byte, saved time.Time) error {
+	index, dataKey, sequence := r.keys(name)
+	id, err := r.client.Incr(sequence).Result()
+	if err != nil {
+		return err
+	}
+
+	member := fmt.Sprintf("%020d", id)
+	score := float64(saved.UnixNano() / int64(time.Microsecond))
+	_, err = r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(dataKey, member, string(data))
+		pipe.ZAdd(index, redis.Z{Score: score, Member: member})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.ttl > 0 {
+		_, err = r.PurgeOlderThan(name, r.ttl)
+	}
+	return err
+}
+
+func (r RedisDAO) storedItems(name string, members []redis.Z) []StoredItem {
+	items := []StoredItem{}
+	if len(members) == 0 {
+		return items
+	}
+
+	_, dataKey, _ := r.keys(name)
+	fields := make([]string, len(members))
+	for index, member := range members {
+		fields[index] = fmt.Sprint(member.Member)
+	}
+	values := r.client.HMGet(dataKey, fields...).Val()
+
+	for index, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		items = append(items, StoredItem{
+			ID:    strings.TrimLeft(fields[index], "0"),
+			Saved: time.Unix(0, int64(members[index].Score)*int64(time.Microsecond)),
+			Data:  data,
+		})
+	}
+	return items
+}
+
+func (r RedisDAO) ListItems(name string, query ItemQuery) []StoredItem {
+	index, _, _ := r.keys(name)
+	count := int64(query.Limit)
+	if count <= 0 {
+		count = -1
+	}
+	members := r.client.ZRangeByScoreWithScores(index, redis.ZRangeBy{
+		Min:    r.minScore(query.Since),
+		Max:    "+inf",
+		Offset: int64(query.Offset),
+		Count:  count,
+	}).Val()
+	return r.storedItems(name, members)
 }
 
 func (r RedisDAO) GetItems(name string) []string {
-	key := r.KeyPrefixed(name)
-	return r.client.SMembers(key).Val()
+	return storedData(r.ListItems(name, ItemQuery{}))
+}
+
+func (r RedisDAO) GetItem(name string, id string) (item StoredItem, ok bool) {
+	index, _, _ := r.keys(name)
+	member := r.member(id)
+	if member == "" {
+		return
+	}
+	score, err := r.client.ZScore(index, member).Result()
+	if err != nil {
+		return
+	}
+
+	items := r.storedItems(name, []redis.Z{{Score: score, Member: member}})
+	if len(items) == 0 || items[0].Saved.Before(effectiveSince(time.Time{}, r.ttl)) {
+		return
+	}
+	return items[0], true
+}
+
+func (r RedisDAO) LatestItems(name string, count int) []StoredItem {
+	if count <= 0 {
+		return []StoredItem{}
+	}
+	index, _, _ := r.keys(name)
+	members := r.client.ZRevRangeByScoreWithScores(index, redis.ZRangeBy{
+		Min:   r.minScore(time.Time{}),
+		Max:   "+inf",
+		Count: int64(count),
+	}).Val()
+	return r.storedItems(name, members)
 }
 
 func (r RedisDAO) CountItems(name string) int64 {
-	key := r.KeyPrefixed(name)
-	return r.client.SCard(key).Val()
+	return r.CountItemsSince(name, time.Time{})
 }
 
-func (r RedisDAO) ProcessItem(item string) genericStruct {
-	var data = genericStruct{}
-	json.Unmarshal([]byte(item), &data)
-	return data
+func (r RedisDAO) CountItemsSince(name string, since time.Time) int64 {
+	index, _, _ := r.keys(name)
+	return r.client.ZCount(index, r.minScore(since), "+inf").Val()
 }
 
-func (r RedisDAO) ProcessItems(items []string) []genericStruct {
-	result := make([]genericStruct, len(items))
+func (r RedisDAO) DeleteItem(name string, id string) (bool, error) {
+	index, dataKey, _ := r.keys(name)
+	member := r.member(id)
+	if member == "" {
+		return false, nil
+	}
+	removed, err := r.client.ZRem(index, member).Result()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, r.client.HDel(dataKey, member).Err()
+}
 
-	for index, item := range items {
-		result[index] = r.ProcessItem(item)
+func (r RedisDAO) PurgeItems(name string) error {
+	index, dataKey, sequence := r.keys(name)
+	return r.client.Del(index, dataKey, sequence).Err()
+}
+
+func (r RedisDAO) PurgeOlderThan(name string, age time.Duration) (int64, error) {
+	index, dataKey, _ := r.keys(name)
+	members, err := r.client.ZRangeByScore(index, redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + r.score(time.Now().Add(-age)),
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return 0, err
 	}
 
-	return result
+	removed := make([]interface{}, len(members))
+	for index, member := range members {
+		removed[index] = member
+	}
+	count, err := r.client.ZRem(index, removed...).Result()
+	if err != nil {
+		return count, err
+	}
+	return count, r.client.HDel(dataKey, members...).Err()
+}
+
+func (r RedisDAO) runsKey(scraper string) string {
+	return RUNS_NAMESPACE + ":" + scraper
+}
+
+func (r RedisDAO) SaveRun(scraper string, data []byte) error {
+	return r.client.RPush(r.runsKey(scraper), string(data)).Err()
+}
+
+func (r RedisDAO) LatestRuns(scraper string, count int) ([]string, error) {
+	start := int64(0)
+	if count > 0 {
+		start = -int64(count)
+	}
+	return r.client.LRange(r.runsKey(scraper), start, -1).Result()
+}
+
+func (r RedisDAO) ProcessItem(item string) genericStruct {
+	return processItem(item)
+}
+
+func (r RedisDAO) ProcessItems(items []string) []genericStruct {
+	return processItems(items)
 }
 
 func (r RedisDAO) String() string {
@@ -87,7 +424,485 @@ func NewRedisDao(address string) (dao RedisDAO) {
 		DB:       0,
 	})
 	dao = RedisDAO{
-		client: client,
+		client:   client,
+		migrated: &sync.Map{},
+	}
+	return
+}
+
+type BoltDAO struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+func boltKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func boltItem(key []byte, value []byte) StoredItem {
+	return StoredItem{
+		ID:    strconv.FormatUint(binary.BigEndian.Uint64(key), 10),
+		Saved: time.Unix(0, int64(binary.BigEndian.Uint64(value[:8]))),
+		Data:  string(value[8:]),
 	}
+}
+
+func (b BoltDAO) WithTTL(ttl time.Duration) BoltDAO {
+	b.ttl = ttl
+	return b
+}
+
+func (b BoltDAO) WithExpiry(ttl time.Duration) DAO {
+	return b.WithTTL(ttl)
+}
+
+func (b BoltDAO) SaveItem(name string, data []byte) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		value := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint64(value, uint64(time.Now().UnixNano()))
+		return bucket.Put(boltKey(id), append(value, data...))
+	})
+	if err == nil && b.ttl > 0 {
+		_, err = b.PurgeOlderThan(name, b.ttl)
+	}
+	return err
+}
+
+func (b BoltDAO) ListItems(name string, query ItemQuery) (items []StoredItem) {
+	items = []StoredItem{}
+	since := effectiveSince(query.Since, b.ttl)
+	skipped := 0
+	b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			item := boltItem(key, value)
+			if item.Saved.Before(since) {
+				continue
+			}
+			if skipped < query.Offset {
+				skipped += 1
+				continue
+			}
+			items = append(items, item)
+			if query.Limit > 0 && len(items) >= query.Limit {
+				break
+			}
+		}
+		return nil
+	})
 	return
 }
+
+func (b BoltDAO) GetItems(name string) []string {
+	return storedData(b.ListItems(name, ItemQuery{}))
+}
+
+func (b BoltDAO) GetItem(name string, id string) (item StoredItem, ok bool) {
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return
+	}
+	b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(name)); bucket != nil {
+			if value := bucket.Get(boltKey(number)); value != nil {
+				item = boltItem(boltKey(number), value)
+				ok = !item.Saved.Before(effectiveSince(time.Time{}, b.ttl))
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) LatestItems(name string, count int) (items []StoredItem) {
+	items = []StoredItem{}
+	since := effectiveSince(time.Time{}, b.ttl)
+	b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil && len(items) < count; key, value = cursor.Prev() {
+			item := boltItem(key, value)
+			if item.Saved.Before(since) {
+				break
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) CountItems(name string) (count int64) {
+	if b.ttl > 0 {
+		return b.CountItemsSince(name, time.Time{})
+	}
+	b.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(name)); bucket != nil {
+			count = int64(bucket.Stats().KeyN)
+		}
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) CountItemsSince(name string, since time.Time) (count int64) {
+	since = effectiveSince(since, b.ttl)
+	if since.IsZero() {
+		return b.CountItems(name)
+	}
+	b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			if boltItem(key, value).Saved.Before(since) {
+				break
+			}
+			count += 1
+		}
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) deleteKeys(name string, selected func(key []byte, value []byte) (bool, bool)) (count int64, err error) {
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			remove, next := selected(key, value)
+			if remove {
+				keys = append(keys, append([]byte{}, key...))
+			}
+			if !next {
+				break
+			}
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		count = int64(len(keys))
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) DeleteItem(name string, id string) (deleted bool, err error) {
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil || bucket.Get(boltKey(number)) == nil {
+			return nil
+		}
+		deleted = true
+		return bucket.Delete(boltKey(number))
+	})
+	return
+}
+
+func (b BoltDAO) PurgeItems(name string) error {
+	_, err := b.deleteKeys(name, func(key []byte, value []byte) (bool, bool) {
+		return true, true
+	})
+	return err
+}
+
+func (b BoltDAO) PurgeOlderThan(name string, age time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-age)
+	return b.deleteKeys(name, func(key []byte, value []byte) (bool, bool) {
+		older := boltItem(key, value).Saved.Before(cutoff)
+		return older, older
+	})
+}
+
+func (b BoltDAO) SaveRun(scraper string, data []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		runs, err := tx.CreateBucketIfNotExists([]byte(RUNS_NAMESPACE))
+		if err != nil {
+			return err
+		}
+		bucket, err := runs.CreateBucketIfNotExists([]byte(scraper))
+		if err != nil {
+			return err
+		}
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(boltKey(id), data)
+	})
+}
+
+func (b BoltDAO) LatestRuns(scraper string, count int) (records []string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		runs := tx.Bucket([]byte(RUNS_NAMESPACE))
+		if runs == nil {
+			return nil
+		}
+		bucket := runs.Bucket([]byte(scraper))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil && (count <= 0 || len(records) < count); key, value = cursor.Prev() {
+			records = append([]string{string(value)}, records...)
+		}
+		return nil
+	})
+	return
+}
+
+func (b BoltDAO) ProcessItem(item string) genericStruct {
+	return processItem(item)
+}
+
+func (b BoltDAO) ProcessItems(items []string) []genericStruct {
+	return processItems(items)
+}
+
+func (b BoltDAO) Close() error {
+	return b.db.Close()
+}
+
+func (b BoltDAO) String() string {
+	return "BoltDAO"
+}
+
+func NewBoltDAO(path string) (dao BoltDAO, err error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return
+	}
+	dao = BoltDAO{
+		db: db,
+	}
+	return
+}
+
+type MemoryDAO struct {
+	mutex    sync.RWMutex
+	ttl      time.Duration
+	sequence map[string]uint64
+	items    map[string][]StoredItem
+	runs     map[string][]string
+}
+
+func (m *MemoryDAO) WithTTL(ttl time.Duration) *MemoryDAO {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ttl = ttl
+	return m
+}
+
+func (m *MemoryDAO) WithExpiry(ttl time.Duration) DAO {
+	return m.WithTTL(ttl)
+}
+
+func (m *MemoryDAO) SaveItem(name string, data []byte) error {
+	m.mutex.Lock()
+	m.sequence[name] += 1
+	m.items[name] = append(m.items[name], StoredItem{
+		ID:    strconv.FormatUint(m.sequence[name], 10),
+		Saved: time.Now(),
+		Data:  string(data),
+	})
+	ttl := m.ttl
+	m.mutex.Unlock()
+
+	if ttl > 0 {
+		m.PurgeOlderThan(name, ttl)
+	}
+	return nil
+}
+
+func (m *MemoryDAO) current(name string, since time.Time) []StoredItem {
+	since = effectiveSince(since, m.ttl)
+	items := m.items[name]
+	for len(items) > 0 && items[0].Saved.Before(since) {
+		items = items[1:]
+	}
+	return items
+}
+
+func (m *MemoryDAO) ListItems(name string, query ItemQuery) []StoredItem {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return append([]StoredItem{}, pageItems(m.current(name, query.Since), query)...)
+}
+
+func (m *MemoryDAO) GetItems(name string) []string {
+	return storedData(m.ListItems(name, ItemQuery{}))
+}
+
+func (m *MemoryDAO) GetItem(name string, id string) (StoredItem, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, item := range m.current(name, time.Time{}) {
+		if item.ID == id {
+			return item, true
+		}
+	}
+	return StoredItem{}, false
+}
+
+func (m *MemoryDAO) LatestItems(name string, count int) []StoredItem {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	items := m.current(name, time.Time{})
+	result := []StoredItem{}
+	for index := len(items) - 1; index >= 0 && len(result) < count; index-- {
+		result = append(result, items[index])
+	}
+	return result
+}
+
+func (m *MemoryDAO) CountItems(name string) int64 {
+	return m.CountItemsSince(name, time.Time{})
+}
+
+func (m *MemoryDAO) CountItemsSince(name string, since time.Time) int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return int64(len(m.current(name, since)))
+}
+
+func (m *MemoryDAO) DeleteItem(name string, id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for index, item := range m.items[name] {
+		if item.ID == id {
+			m.items[name] = append(m.items[name][:index:index], m.items[name][index+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemoryDAO) PurgeItems(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.items, name)
+	return nil
+}
+
+func (m *MemoryDAO) PurgeOlderThan(name string, age time.Duration) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	cutoff := time.Now().Add(-age)
+	items := m.items[name]
+	count := 0
+	for count < len(items) && items[count].Saved.Before(cutoff) {
+		count += 1
+	}
+	m.items[name] = append([]StoredItem{}, items[count:]...)
+	return int64(count), nil
+}
+
+func (m *MemoryDAO) SaveRun(scraper string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.runs[scraper] = append(m.runs[scraper], string(data))
+	return nil
+}
+
+func (m *MemoryDAO) LatestRuns(scraper string, count int) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	runs := m.runs[scraper]
+	if count > 0 && len(runs) > count {
+		runs = runs[len(runs)-count:]
+	}
+	return append([]string{}, runs...), nil
+}
+
+func (m *MemoryDAO) ProcessItem(item string) genericStruct {
+	return processItem(item)
+}
+
+func (m *MemoryDAO) ProcessItems(items []string) []genericStruct {
+	return processItems(items)
+}
+
+func (m *MemoryDAO) String() string {
+	return "MemoryDAO"
+}
+
+func NewMemoryDAO() *MemoryDAO {
+	return &MemoryDAO{
+		sequence: make(map[string]uint64),
+		items:    make(map[string][]StoredItem),
+		runs:     make(map[string][]string),
+	}
+}
+
+func NewDAO(storage string) (DAO, error) {
+	chunks := strings.SplitN(storage, "://", 2)
+	if len(chunks) != 2 {
+		return nil, errors.New(fmt.Sprintf("Invalid storage: %s", storage))
+	}
+
+	location, rawQuery := chunks[1], ""
+	if index := strings.Index(location, "?"); index >= 0 {
+		location, rawQuery = location[:index], location[index+1:]
+	}
+	params, err := URL.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	var ttl time.Duration
+	if value := params.Get("ttl"); value != "" {
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid storage ttl: %s", value))
+		}
+	}
+
+	scheme := strings.ToLower(chunks[0])
+	if scheme == STORAGE_MEMORY {
+		return NewMemoryDAO().WithTTL(ttl), nil
+	}
+	if location == "" {
+		return nil, errors.New(fmt.Sprintf("Invalid storage: %s", storage))
+	}
+
+	switch scheme {
+	case STORAGE_REDIS:
+		return NewRedisDao(location).WithTTL(ttl).WithLegacyTimeField(params.Get("legacytime")), nil
+	case STORAGE_BOLT:
+		dao, err := NewBoltDAO(location)
+		if err != nil {
+			return nil, err
+		}
+		return dao.WithTTL(ttl), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown storage: %s", chunks[0]))
+}
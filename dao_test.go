@@ -0,0 +1,214 @@
+package gotana
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMemoryDAOConformance(t *testing.T) {
+	if err := CheckDAOConformance(func() DAO { return NewMemoryDAO() }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltDAOConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotana-bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "items.db")
+	err = CheckDAOConformance(func() DAO {
+		dao, err := NewBoltDAO(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dao
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewDAO(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		storage  string
+		expected string
+	}{
+		{"memory://", "*gotana.MemoryDAO"},
+		{"MEMORY://?ttl=1h", "*gotana.MemoryDAO"},
+		{"bolt://" + filepath.Join(dir, "items.db"), "gotana.BoltDAO"},
+		{"bolt://" + filepath.Join(dir, "expiring.db") + "?ttl=1h", "gotana.BoltDAO"},
+		{"redis://localhost:6379", "gotana.RedisDAO"},
+		{"bolt://", ""},
+		{"bolt://" + filepath.Join(dir, "missing", "items.db"), ""},
+		{"bolt://items.db?ttl=abc", ""},
+		{"foo://x", ""},
+		{"items.db", ""},
+	}
+
+	for _, test := range tests {
+		dao, err := NewDAO(test.storage)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("NewDAO(%q) returned %T, expected error", test.storage, dao)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewDAO(%q) failed: %s", test.storage, err)
+			continue
+		}
+		if kind := reflect.TypeOf(dao).String(); kind != test.expected {
+			t.Errorf("NewDAO(%q) returned %s, expected %s", test.storage, kind, test.expected)
+		}
+		if bolt, ok := dao.(BoltDAO); ok {
+			bolt.Close()
+		}
+	}
+}
+
+func TestGetDAO(t *testing.T) {
+	memory := NewMemoryDAO()
+	tests := []struct {
+		engine   *Engine
+		expected string
+	}{
+		{nil, "<nil>"},
+		{NewEngine(), "<nil>"},
+		{NewEngine().SetDAO(memory), "*gotana.MemoryDAO"},
+		{NewEngine().FromConfig(&ScraperConfig{RedisAddress: "localhost:6379"}), "gotana.RedisDAO"},
+		{NewEngine().FromConfig(&ScraperConfig{RedisAddress: "localhost:6379", Storage: "memory://"}), "*gotana.MemoryDAO"},
+	}
+	for index, test := range tests {
+		if kind := reflect.TypeOf(GetDAO(test.engine)); fmt.Sprint(kind) != test.expected {
+			t.Errorf("GetDAO of engine %d returned %v, expected %s", index, kind, test.expected)
+		}
+	}
+	engine := NewEngine().SetDAO(memory)
+	if GetDAO(engine) != GetDAO(engine) {
+		t.Error("GetDAO returned different storages for one engine")
+	}
+}
+
+func TestBoltDAOKeepsItemsAfterEngineStops(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.db")
+	data := `
+storage: bolt://` + path + `
+extensions:
+- name: save
+scrapers:
+- name: a
+  url: http://a.com
+  requestlimit: 1
+`
+	config := &ScraperConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine().FromConfig(config)
+	scraper := engine.GetScraper("a")
+	for index := 0; index < 100; index++ {
+		item := NewGenericItem("post", ScrapedItem{scraper: scraper})
+		item.Fields["index"] = index
+		engine.chItems <- item
+	}
+	engine.scrapers = nil
+	engine.Stop()
+
+	dao, err := NewBoltDAO(path)
+	if err != nil {
+		t.Fatalf("Storage was not closed on stop: %s", err)
+	}
+	defer dao.Close()
+	if count := dao.CountItems("a"); count != 100 {
+		t.Errorf("CountItems after stop returned %d, expected 100", count)
+	}
+	items := dao.GetItems("a")
+	if len(items) != 100 || items[0] != `{"index":0}` || items[99] != `{"index":99}` {
+		t.Errorf("GetItems after stop returned %d items in wrong order", len(items))
+	}
+}
+
+func checkRedisDAOConformance(t *testing.T, address string) {
+	dao := NewRedisDao(address)
+	if err := dao.client.Ping().Err(); err != nil {
+		t.Fatalf("Cannot connect to Redis at %s: %s", address, err)
+	}
+	if err := CheckDAOConformance(func() DAO { return NewRedisDao(address) }); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dao.client.Keys(dao.KeyPrefixed("*conformance-*")).Val(); len(keys) != 0 {
+		t.Errorf("Conformance checks left keys in Redis: %v", keys)
+	}
+}
+
+func TestRedisDAOConformance(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	checkRedisDAOConformance(t, server.Addr())
+	if address := os.Getenv("GOTANA_REDIS_ADDRESS"); address != "" {
+		checkRedisDAOConformance(t, address)
+	}
+}
+
+func TestRedisDAOMigration(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second).UTC()
+	server.SAdd("gotana-golang",
+		`{"title":"c"}`,
+		`{"title":"b","scraped":"`+old.Add(time.Hour).Format(time.RFC3339)+`"}`,
+		`{"title":"a","scraped":`+strconv.FormatInt(old.Unix(), 10)+`}`,
+	)
+
+	dao := NewRedisDao(server.Addr()).WithLegacyTimeField("scraped")
+	expected := []string{
+		`{"title":"a","scraped":` + strconv.FormatInt(old.Unix(), 10) + `}`,
+		`{"title":"b","scraped":"` + old.Add(time.Hour).Format(time.RFC3339) + `"}`,
+		`{"title":"c"}`,
+	}
+	if items := dao.GetItems("golang"); !reflect.DeepEqual(items, expected) {
+		t.Fatalf("Migrated items are %v, expected %v", items, expected)
+	}
+	if server.Exists("gotana-golang") {
+		t.Error("Legacy set was not removed")
+	}
+
+	items := dao.ListItems("golang", ItemQuery{})
+	if !items[0].Saved.Equal(old) || !items[1].Saved.Equal(old.Add(time.Hour)) {
+		t.Errorf("Migrated items were saved at %s and %s, expected embedded times", items[0].Saved, items[1].Saved)
+	}
+	if time.Since(items[2].Saved) > time.Minute {
+		t.Errorf("Item without embedded time was saved at %s, expected migration time", items[2].Saved)
+	}
+	if count := dao.CountItemsSince("golang", time.Now().Add(-24*time.Hour)); count != 1 {
+		t.Errorf("CountItemsSince returned %d, expected only item without embedded time", count)
+	}
+
+	if count, err := dao.PurgeOlderThan("golang", 24*time.Hour); err != nil || count != 2 {
+		t.Errorf("PurgeOlderThan returned %d, %v, expected 2 old migrated items", count, err)
+	}
+	if count := NewRedisDao(server.Addr()).CountItems("golang"); count != 1 {
+		t.Errorf("CountItems after migration returned %d, expected 1", count)
+	}
+}
@@ -0,0 +1,361 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type DAOFactory func() DAO
+
+type daoCheck struct {
+	name  string
+	check func(dao DAO, name string) error
+}
+
+var daoChecks = []daoCheck{
+	{"empty", checkDAOEmpty},
+	{"save", checkDAOSave},
+	{"isolation", checkDAOIsolation},
+	{"process", checkDAOProcess},
+	{"pagination", checkDAOPagination},
+	{"since", checkDAOSince},
+	{"lookup", checkDAOLookup},
+	{"latest", checkDAOLatest},
+	{"delete", checkDAODelete},
+	{"purge", checkDAOPurge},
+	{"expiry", checkDAOExpiry},
+}
+
+func daoRecords(count int) []string {
+	records := make([]string, count)
+	for index := range records {
+		records[index] = fmt.Sprintf(`{"index":%d,"title":"item %d"}`, index, index)
+	}
+	return records
+}
+
+func saveDAORecords(dao DAO, name string, records []string) error {
+	for _, record := range records {
+		if err := dao.SaveItem(name, []byte(record)); err != nil {
+			return errors.New(fmt.Sprintf("SaveItem(%q) failed: %s", name, err))
+		}
+	}
+	return nil
+}
+
+func sameRecords(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for index := range expected {
+		if expected[index] != actual[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkDAOEmpty(dao DAO, name string) error {
+	if items := dao.GetItems(name); len(items) != 0 {
+		return errors.New(fmt.Sprintf("GetItems of unknown name returned %d items", len(items)))
+	}
+	if count := dao.CountItems(name); count != 0 {
+		return errors.New(fmt.Sprintf("CountItems of unknown name returned %d", count))
+	}
+	return nil
+}
+
+func checkDAOSave(dao DAO, name string) error {
+	records := daoRecords(5)
+	if err := saveDAORecords(dao, name, records); err != nil {
+		return err
+	}
+	if items := dao.GetItems(name); !sameRecords(records, items) {
+		return errors.New(fmt.Sprintf("GetItems returned %v, expected %v", items, records))
+	}
+	if count := dao.CountItems(name); count != int64(len(records)) {
+		return errors.New(fmt.Sprintf("CountItems returned %d, expected %d", count, len(records)))
+	}
+	return nil
+}
+
+func checkDAOIsolation(dao DAO, name string) error {
+	other := name + "-other"
+	if err := saveDAORecords(dao, name, daoRecords(2)); err != nil {
+		return err
+	}
+	if err := saveDAORecords(dao, other, daoRecords(3)); err != nil {
+		return err
+	}
+	if count := dao.CountItems(name); count != 2 {
+		return errors.New(fmt.Sprintf("CountItems(%q) returned %d, expected 2", name, count))
+	}
+	if count := dao.CountItems(other); count != 3 {
+		return errors.New(fmt.Sprintf("CountItems(%q) returned %d, expected 3", other, count))
+	}
+	return nil
+}
+
+func checkDAOProcess(dao DAO, name string) error {
+	processed := dao.ProcessItem(`{"title":"item","price":2.5,"tags":["a"]}`)
+	expected := genericStruct{"title": "item", "price": 2.5, "tags": []interface{}{"a"}}
+	if !reflect.DeepEqual(processed, expected) {
+		return errors.New(fmt.Sprintf("ProcessItem returned %v, expected %v", processed, expected))
+	}
+	if processed := dao.ProcessItem("not json"); processed == nil || len(processed) != 0 {
+		return errors.New(fmt.Sprintf("ProcessItem of invalid data returned %v, expected empty struct", processed))
+	}
+
+	records := daoRecords(3)
+	all := dao.ProcessItems(records)
+	if len(all) != len(records) {
+		return errors.New(fmt.Sprintf("ProcessItems returned %d items, expected %d", len(all), len(records)))
+	}
+	for index, item := range all {
+		if item["index"] != float64(index) {
+			return errors.New(fmt.Sprintf("ProcessItems changed order of items: %v", all))
+		}
+	}
+	if empty := dao.ProcessItems([]string{}); len(empty) != 0 {
+		return errors.New(fmt.Sprintf("ProcessItems of no items returned %v", empty))
+	}
+	return nil
+}
+
+func checkDAOPagination(dao DAO, name string) error {
+	records := daoRecords(7)
+	if err := saveDAORecords(dao, name, records); err != nil {
+		return err
+	}
+
+	pages := []struct {
+		query    ItemQuery
+		expected []string
+	}{
+		{ItemQuery{}, records},
+		{ItemQuery{Limit: 3}, records[:3]},
+		{ItemQuery{Offset: 3, Limit: 3}, records[3:6]},
+		{ItemQuery{Offset: 6, Limit: 3}, records[6:]},
+		{ItemQuery{Offset: 5}, records[5:]},
+		{ItemQuery{Offset: 10, Limit: 3}, []string{}},
+	}
+	for _, page := range pages {
+		if items := storedData(dao.ListItems(name, page.query)); !sameRecords(page.expected, items) {
+			return errors.New(fmt.Sprintf("ListItems(%+v) returned %v, expected %v", page.query, items, page.expected))
+		}
+	}
+	return nil
+}
+
+func checkDAOSince(dao DAO, name string) error {
+	records := daoRecords(4)
+	if err := saveDAORecords(dao, name, records[:2]); err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := saveDAORecords(dao, name, records[2:]); err != nil {
+		return err
+	}
+
+	if items := storedData(dao.ListItems(name, ItemQuery{Since: since})); !sameRecords(records[2:], items) {
+		return errors.New(fmt.Sprintf("ListItems since returned %v, expected %v", items, records[2:]))
+	}
+	if count := dao.CountItemsSince(name, since); count != 2 {
+		return errors.New(fmt.Sprintf("CountItemsSince returned %d, expected 2", count))
+	}
+	if count := dao.CountItemsSince(name, time.Time{}); count != int64(len(records)) {
+		return errors.New(fmt.Sprintf("CountItemsSince without time returned %d, expected %d", count, len(records)))
+	}
+	for _, item := range dao.ListItems(name, ItemQuery{}) {
+		if item.Saved.IsZero() || item.Saved.After(time.Now()) {
+			return errors.New(fmt.Sprintf("Item %s has invalid saved time %s", item.ID, item.Saved))
+		}
+	}
+	return nil
+}
+
+func checkDAOLookup(dao DAO, name string) error {
+	if err := saveDAORecords(dao, name, daoRecords(3)); err != nil {
+		return err
+	}
+
+	ids := map[string]bool{}
+	for _, stored := range dao.ListItems(name, ItemQuery{}) {
+		if stored.ID == "" || ids[stored.ID] {
+			return errors.New(fmt.Sprintf("ListItems returned empty or repeated id %q", stored.ID))
+		}
+		ids[stored.ID] = true
+
+		item, ok := dao.GetItem(name, stored.ID)
+		if !ok || item.Data != stored.Data || item.ID != stored.ID {
+			return errors.New(fmt.Sprintf("GetItem(%q) returned %+v, expected %+v", stored.ID, item, stored))
+		}
+	}
+	if _, ok := dao.GetItem(name, "999999"); ok {
+		return errors.New("GetItem of unknown id returned item")
+	}
+	if _, ok := dao.GetItem(name+"-other", "1"); ok {
+		return errors.New("GetItem of unknown name returned item")
+	}
+	return nil
+}
+
+func checkDAOLatest(dao DAO, name string) error {
+	records := daoRecords(5)
+	if err := saveDAORecords(dao, name, records); err != nil {
+		return err
+	}
+
+	expected := []string{records[4], records[3]}
+	if items := storedData(dao.LatestItems(name, 2)); !sameRecords(expected, items) {
+		return errors.New(fmt.Sprintf("LatestItems returned %v, expected %v", items, expected))
+	}
+	if items := dao.LatestItems(name, 10); len(items) != len(records) {
+		return errors.New(fmt.Sprintf("LatestItems returned %d items, expected %d", len(items), len(records)))
+	}
+	if items := dao.LatestItems(name+"-other", 2); len(items) != 0 {
+		return errors.New(fmt.Sprintf("LatestItems of unknown name returned %d items", len(items)))
+	}
+	return nil
+}
+
+func checkDAODelete(dao DAO, name string) error {
+	records := daoRecords(3)
+	if err := saveDAORecords(dao, name, records); err != nil {
+		return err
+	}
+
+	items := dao.ListItems(name, ItemQuery{})
+	if len(items) != len(records) {
+		return errors.New(fmt.Sprintf("ListItems returned %d items, expected %d", len(items), len(records)))
+	}
+	deleted, err := dao.DeleteItem(name, items[1].ID)
+	if err != nil || !deleted {
+		return errors.New(fmt.Sprintf("DeleteItem(%q) returned %v, %v", items[1].ID, deleted, err))
+	}
+	if deleted, _ = dao.DeleteItem(name, items[1].ID); deleted {
+		return errors.New("DeleteItem of deleted item returned true")
+	}
+
+	expected := []string{records[0], records[2]}
+	if remaining := dao.GetItems(name); !sameRecords(expected, remaining) {
+		return errors.New(fmt.Sprintf("GetItems after delete returned %v, expected %v", remaining, expected))
+	}
+	if count := dao.CountItems(name); count != 2 {
+		return errors.New(fmt.Sprintf("CountItems after delete returned %d, expected 2", count))
+	}
+	return nil
+}
+
+func checkDAOPurge(dao DAO, name string) error {
+	other := name + "-other"
+	if err := saveDAORecords(dao, name, daoRecords(3)); err != nil {
+		return err
+	}
+	if err := saveDAORecords(dao, other, daoRecords(2)); err != nil {
+		return err
+	}
+
+	if count, err := dao.PurgeOlderThan(name, time.Hour); err != nil || count != 0 {
+		return errors.New(fmt.Sprintf("PurgeOlderThan of recent items returned %d, %v", count, err))
+	}
+	time.Sleep(10 * time.Millisecond)
+	if count, err := dao.PurgeOlderThan(name, 5*time.Millisecond); err != nil || count != 3 {
+		return errors.New(fmt.Sprintf("PurgeOlderThan returned %d, %v, expected 3", count, err))
+	}
+	if count := dao.CountItems(name); count != 0 {
+		return errors.New(fmt.Sprintf("CountItems after purge returned %d", count))
+	}
+
+	if err := dao.PurgeItems(other); err != nil {
+		return errors.New(fmt.Sprintf("PurgeItems failed: %s", err))
+	}
+	if items := dao.GetItems(other); len(items) != 0 {
+		return errors.New(fmt.Sprintf("GetItems after purge returned %v", items))
+	}
+
+	if err := saveDAORecords(dao, other, daoRecords(1)); err != nil {
+		return err
+	}
+	if count := dao.CountItems(other); count != 1 {
+		return errors.New(fmt.Sprintf("CountItems after purge and save returned %d, expected 1", count))
+	}
+	return nil
+}
+
+func checkDAOExpiry(dao DAO, name string) error {
+	expiring, ok := dao.(ExpiringDAO)
+	if !ok {
+		return nil
+	}
+	plain, dao := dao, expiring.WithExpiry(50*time.Millisecond)
+
+	records := daoRecords(3)
+	if err := saveDAORecords(dao, name, records[:2]); err != nil {
+		return err
+	}
+	expired := dao.ListItems(name, ItemQuery{})
+	if len(expired) != 2 {
+		return errors.New(fmt.Sprintf("ListItems before expiry returned %d items, expected 2", len(expired)))
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	if items := dao.GetItems(name); len(items) != 0 {
+		return errors.New(fmt.Sprintf("GetItems returned expired items %v", items))
+	}
+	if count := dao.CountItems(name); count != 0 {
+		return errors.New(fmt.Sprintf("CountItems counted %d expired items", count))
+	}
+	if _, ok := dao.GetItem(name, expired[0].ID); ok {
+		return errors.New(fmt.Sprintf("GetItem(%q) returned expired item", expired[0].ID))
+	}
+
+	if err := saveDAORecords(dao, name, records[2:]); err != nil {
+		return err
+	}
+	if items := storedData(dao.LatestItems(name, 10)); !sameRecords(records[2:], items) {
+		return errors.New(fmt.Sprintf("LatestItems after expiry returned %v, expected %v", items, records[2:]))
+	}
+	if count := dao.CountItemsSince(name, time.Time{}); count != 1 {
+		return errors.New(fmt.Sprintf("CountItemsSince after expiry returned %d, expected 1", count))
+	}
+	if items := plain.ListItems(name, ItemQuery{}); len(items) != 1 {
+		return errors.New(fmt.Sprintf("Saving item left %d items in storage, expected expired items to be removed", len(items)))
+	}
+	return nil
+}
+
+func CheckDAOConformance(factory DAOFactory) error {
+	prefix := fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+
+	var failures []string
+	for _, check := range daoChecks {
+		dao := factory()
+		if dao == nil {
+			return errors.New("DAO factory returned nil")
+		}
+		name := prefix + "-" + check.name
+		if err := check.check(dao, name); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", check.name, err))
+		}
+		for _, purged := range []string{name, name + "-other"} {
+			if err := dao.PurgeItems(purged); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: PurgeItems(%q) failed: %s", check.name, purged, err))
+			}
+		}
+		if closer, ok := dao.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(fmt.Sprintf("DAO does not conform. %s", strings.Join(failures, "; ")))
+	}
+	return nil
+}
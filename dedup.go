@@ -0,0 +1,252 @@
+package gotana
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	DROP_REASON_DUPLICATE = "duplicate"
+	SEEN_STORE_MEMORY     = "memory"
+	SEEN_STORE_DISK       = "disk"
+	SEEN_STORE_REDIS      = "redis"
+	SEEN_NAMESPACE        = "gotana:seen:"
+)
+
+type DedupKeyFunc func(item SaveableItem) (string, error)
+
+type SeenSet interface {
+	Add(scope string, key string) (bool, error)
+	Close() error
+}
+
+type MemorySeenSet struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+func (set *MemorySeenSet) Add(scope string, key string) (bool, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	entry := scope + "\t" + key
+	if set.seen[entry] {
+		return false, nil
+	}
+	set.seen[entry] = true
+	return true, nil
+}
+
+func (set *MemorySeenSet) Close() error {
+	return nil
+}
+
+func NewMemorySeenSet() *MemorySeenSet {
+	return &MemorySeenSet{seen: make(map[string]bool)}
+}
+
+type DiskSeenSet struct {
+	MemorySeenSet
+	file *os.File
+}
+
+func (set *DiskSeenSet) Add(scope string, key string) (bool, error) {
+	added, _ := set.MemorySeenSet.Add(scope, key)
+	if !added {
+		return false, nil
+	}
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	_, err := set.file.WriteString(scope + "\t" + key + "\n")
+	return true, err
+}
+
+func (set *DiskSeenSet) Close() error {
+	return set.file.Close()
+}
+
+func NewDiskSeenSet(path string, persist bool) (set *DiskSeenSet, err error) {
+	flags := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if !persist {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return
+	}
+
+	set = &DiskSeenSet{MemorySeenSet: MemorySeenSet{seen: make(map[string]bool)}, file: file}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			set.seen[line] = true
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return
+}
+
+type RedisSeenSet struct {
+	client  *redis.Client
+	persist bool
+	mutex   sync.Mutex
+	cleared map[string]bool
+}
+
+func (set *RedisSeenSet) key(scope string) string {
+	return SEEN_NAMESPACE + scope
+}
+
+func (set *RedisSeenSet) Add(scope string, key string) (bool, error) {
+	if !set.persist {
+		set.mutex.Lock()
+		if !set.cleared[scope] {
+			set.client.Del(set.key(scope))
+			set.cleared[scope] = true
+		}
+		set.mutex.Unlock()
+	}
+
+	added, err := set.client.SAdd(set.key(scope), key).Result()
+	if err != nil {
+		return true, err
+	}
+	return added == 1, nil
+}
+
+func (set *RedisSeenSet) Close() error {
+	return set.client.Close()
+}
+
+func NewRedisSeenSet(address string, persist bool) *RedisSeenSet {
+	client := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: "",
+		DB:       0,
+	})
+	return &RedisSeenSet{client: client, persist: persist, cleared: make(map[string]bool)}
+}
+
+func hashKey(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func FieldsKey(fields ...string) DedupKeyFunc {
+	return func(item SaveableItem) (string, error) {
+		data, err := item.RecordData()
+		if err != nil {
+			return "", err
+		}
+		if len(fields) == 0 {
+			return hashKey(data), nil
+		}
+
+		record := map[string]interface{}{}
+		if err = json.Unmarshal(data, &record); err != nil {
+			return "", err
+		}
+
+		values := make([]interface{}, len(fields))
+		found := false
+		for index, field := range fields {
+			values[index] = record[field]
+			found = found || record[field] != nil
+		}
+		if !found {
+			return "", nil
+		}
+
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return "", err
+		}
+		return hashKey(encoded), nil
+	}
+}
+
+func DedupStage(key DedupKeyFunc, seen SeenSet) StageFunc {
+	return func(item SaveableItem) ([]SaveableItem, error) {
+		value, err := key(item)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return Keep(item)
+		}
+
+		scope := ""
+		scraper := item.Scraper()
+		if scraper != nil {
+			scope = scraper.Name
+		}
+
+		added, err := seen.Add(scope, value)
+		if err != nil {
+			Logger().Warningf("Seen set failed, keeping item of %s: %s", scope, err)
+			return Keep(item)
+		}
+		if !added {
+			if scraper != nil && scraper.engine != nil {
+				scraper.engine.Meta.IncrDuplicates(scraper)
+			}
+			return nil, Drop(DROP_REASON_DUPLICATE)
+		}
+		return Keep(item)
+	}
+}
+
+type DedupConfig struct {
+	Fields  []string
+	Store   string
+	Path    string
+	Address string
+	Persist bool
+}
+
+func NewSeenSet(config DedupConfig) (SeenSet, error) {
+	switch strings.ToLower(config.Store) {
+	case "", SEEN_STORE_MEMORY:
+		return NewMemorySeenSet(), nil
+	case SEEN_STORE_DISK:
+		if config.Path == "" {
+			return nil, errors.New("Disk seen set requires path")
+		}
+		set, err := NewDiskSeenSet(config.Path, config.Persist)
+		if err != nil {
+			return nil, err
+		}
+		return set, nil
+	case SEEN_STORE_REDIS:
+		if config.Address == "" {
+			return nil, errors.New("Redis seen set requires address")
+		}
+		return NewRedisSeenSet(config.Address, config.Persist), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown seen set store: %s", config.Store))
+}
+
+func init() {
+	RegisterClosingStage("dedup", func(options ComponentOptions) (StageFunc, io.Closer, error) {
+		config := DedupConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, nil, err
+		}
+		seen, err := NewSeenSet(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return DedupStage(FieldsKey(config.Fields...), seen), seen, nil
+	})
+}
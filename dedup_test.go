@@ -0,0 +1,182 @@
+package gotana
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func dedupItem(scraper *Scraper, fields map[string]interface{}) SaveableItem {
+	item := NewGenericItem("post", ScrapedItem{scraper: scraper})
+	for name, value := range fields {
+		item.Fields[name] = value
+	}
+	return item
+}
+
+func TestFieldsKey(t *testing.T) {
+	tests := []struct {
+		fields []string
+		first  map[string]interface{}
+		second map[string]interface{}
+		equal  bool
+	}{
+		{[]string{"url"}, map[string]interface{}{"url": "u", "ts": 1}, map[string]interface{}{"url": "u", "ts": 2}, true},
+		{[]string{"url"}, map[string]interface{}{"url": "u"}, map[string]interface{}{"url": "v"}, false},
+		{[]string{"url", "lang"}, map[string]interface{}{"url": "u", "lang": "en"}, map[string]interface{}{"url": "u", "lang": "de"}, false},
+		{nil, map[string]interface{}{"url": "u", "ts": 1}, map[string]interface{}{"url": "u", "ts": 1}, true},
+		{nil, map[string]interface{}{"url": "u", "ts": 1}, map[string]interface{}{"url": "u", "ts": 2}, false},
+	}
+
+	for _, test := range tests {
+		key := FieldsKey(test.fields...)
+		first, err := key(dedupItem(nil, test.first))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := key(dedupItem(nil, test.second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == "" || (first == second) != test.equal {
+			t.Errorf("Keys of %v and %v on %v are %q and %q, expected equal: %v", test.first, test.second, test.fields, first, second, test.equal)
+		}
+	}
+
+	if key, _ := FieldsKey("missing")(dedupItem(nil, map[string]interface{}{"url": "u"})); key != "" {
+		t.Errorf("Key of item without fields is %q, expected empty", key)
+	}
+}
+
+func checkSeenSet(t *testing.T, name string, seen SeenSet) {
+	steps := []struct {
+		scope string
+		key   string
+		added bool
+	}{
+		{"a", "k1", true},
+		{"a", "k1", false},
+		{"b", "k1", true},
+		{"a", "k2", true},
+	}
+	for _, step := range steps {
+		if added, err := seen.Add(step.scope, step.key); err != nil || added != step.added {
+			t.Errorf("%s: Add(%s, %s) returned %v, %v, expected %v", name, step.scope, step.key, added, err, step.added)
+		}
+	}
+	if err := seen.Close(); err != nil {
+		t.Errorf("%s: Close failed: %s", name, err)
+	}
+}
+
+func TestSeenSets(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "seen.txt")
+
+	tests := []struct {
+		name string
+		open func(persist bool) SeenSet
+	}{
+		{"disk", func(persist bool) SeenSet {
+			seen, err := NewDiskSeenSet(path, persist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return seen
+		}},
+		{"redis", func(persist bool) SeenSet {
+			return NewRedisSeenSet(server.Addr(), persist)
+		}},
+	}
+
+	checkSeenSet(t, "memory", NewMemorySeenSet())
+	for _, test := range tests {
+		checkSeenSet(t, test.name, test.open(true))
+		if added, _ := test.open(true).Add("a", "k1"); added {
+			t.Errorf("%s: persisted key added again", test.name)
+		}
+		if added, _ := test.open(false).Add("a", "k1"); !added {
+			t.Errorf("%s: key of previous run not cleared", test.name)
+		}
+	}
+
+	for _, key := range server.Keys() {
+		if key != SEEN_NAMESPACE+"a" && key != SEEN_NAMESPACE+"b" {
+			t.Errorf("Redis seen set stored key %s outside %s namespace", key, SEEN_NAMESPACE)
+		}
+	}
+}
+
+func TestRedisSeenSetDoesNotShareDAOKeys(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	dao := NewRedisDao(server.Addr())
+	if err := dao.SaveItem("seen-x", []byte(`{"url":"u"}`)); err != nil {
+		t.Fatal(err)
+	}
+	seen := NewRedisSeenSet(server.Addr(), false)
+	if _, err := seen.Add("x", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if items := dao.LatestItems("seen-x", 10); len(items) != 1 {
+		t.Errorf("Seen set of scraper x changed items of scraper seen-x: %v", items)
+	}
+}
+
+func TestDedupStage(t *testing.T) {
+	engine := NewEngine()
+	first := NewScraper(ScraperParams{Name: "a", Url: "http://a.com"})
+	second := NewScraper(ScraperParams{Name: "b", Url: "http://b.com"})
+	engine.AddScrapers(first, second)
+	stage := DedupStage(FieldsKey("url"), NewMemorySeenSet())
+
+	tests := []struct {
+		scraper *Scraper
+		fields  map[string]interface{}
+		kept    bool
+	}{
+		{first, map[string]interface{}{"url": "u1", "ts": 1}, true},
+		{first, map[string]interface{}{"url": "u1", "ts": 2}, false},
+		{second, map[string]interface{}{"url": "u1", "ts": 2}, true},
+		{first, map[string]interface{}{"ts": 3}, true},
+		{first, map[string]interface{}{"ts": 3}, true},
+	}
+	for _, test := range tests {
+		items, err := stage(dedupItem(test.scraper, test.fields))
+		if kept := err == nil && len(items) == 1; kept != test.kept {
+			t.Errorf("Dedup of %v from %s kept: %v (%v), expected %v", test.fields, test.scraper.Name, kept, err, test.kept)
+		}
+		if !test.kept && err != Drop(DROP_REASON_DUPLICATE) {
+			t.Errorf("Dedup of %v returned %v, expected duplicate drop", test.fields, err)
+		}
+	}
+	if duplicates := engine.Meta.ScraperStats["a"].duplicates; duplicates != 1 {
+		t.Errorf("Counted %d duplicates, expected 1", duplicates)
+	}
+}
+
+func TestDedupStageFailsOpen(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := NewRedisSeenSet(server.Addr(), true)
+	defer seen.Close()
+	server.Close()
+
+	stage := DedupStage(FieldsKey("url"), seen)
+	for i := 0; i < 2; i++ {
+		if items, err := stage(dedupItem(nil, map[string]interface{}{"url": "u1"})); err != nil || len(items) != 1 {
+			t.Errorf("Dedup with unreachable store returned %v, %v, expected item kept", items, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gotana
 
 import (
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,42 +15,71 @@ const (
 	STATE_STOPPING = "STOPPING"
 )
 
+type extensionEvent struct {
+	event string
+	prm   extensionParameters
+}
+
 type Engine struct {
-	state             string
-	wg                sync.WaitGroup
-	limitCrawl        int
-	limitFail         int
-	handler           ScrapingHandlerFunc
-	finished          int
-	scrapers          []*Scraper
-	requestMiddleware []RequestMiddlewareFunc
-	extensions        []Extension
-	chDone            chan struct{}
-	chScraped         chan ScrapedItem
-	chItems           chan SaveableItem
-	Meta              *EngineMeta
-	Config            *ScraperConfig
+	state              string
+	wg                 sync.WaitGroup
+	events             sync.WaitGroup
+	closeOnce          sync.Once
+	looping            bool
+	limitCrawl         int
+	limitFail          int
+	handler            ScrapingHandlerFunc
+	handlers           map[string]ScrapingHandlerFunc
+	finished           int
+	scrapers           []*Scraper
+	requestMiddleware  []RequestMiddlewareFunc
+	responseMiddleware []ResponseMiddlewareFunc
+	extensions         []Extension
+	pipeline           *Pipeline
+	dao                DAO
+	daoOnce            sync.Once
+	registry           *Registry
+	Active             *ActiveComponents
+	chDone             chan struct{}
+	chScraped          chan ScrapedItem
+	chItems            chan SaveableItem
+	chEvents           chan extensionEvent
+	chFlush            chan chan struct{}
+	Meta               *EngineMeta
+	Config             *ScraperConfig
 }
 
 func (engine *Engine) notifyExtensions(event string, prm extensionParameters) {
-	go func() {
-		switch event {
-		case EVENT_SCRAPER_OPENED:
-			for _, extension := range engine.extensions {
-				extension.ScraperStarted(prm.scraper)
-			}
-		case EVENT_SCRAPER_CLOSED:
-			for _, extension := range engine.extensions {
-				extension.ScraperStopped(prm.scraper)
-			}
-		case EVENT_SAVEABLE_EXTRACTED:
-			for _, extension := range engine.extensions {
-				extension.ItemScraped(prm.scraper, prm.item)
-			}
-		default:
-			panic("Inappropriate event: " + event)
+	engine.events.Add(1)
+	engine.chEvents <- extensionEvent{event: event, prm: prm}
+}
+
+func (engine *Engine) eventLoop() {
+	for event := range engine.chEvents {
+		engine.handleEvent(event)
+	}
+}
+
+func (engine *Engine) handleEvent(event extensionEvent) {
+	defer engine.events.Done()
+
+	prm := event.prm
+	switch event.event {
+	case EVENT_SCRAPER_OPENED:
+		for _, extension := range engine.extensions {
+			extension.ScraperStarted(prm.scraper)
+		}
+	case EVENT_SCRAPER_CLOSED:
+		for _, extension := range engine.extensions {
+			extension.ScraperStopped(prm.scraper)
+		}
+	case EVENT_SAVEABLE_EXTRACTED:
+		for _, extension := range engine.extensions {
+			extension.ItemScraped(prm.scraper, prm.item)
 		}
-	}()
+	default:
+		panic("Inappropriate event: " + event.event)
+	}
 }
 
 func (engine *Engine) SetHandler(handler ScrapingHandlerFunc) *Engine {
@@ -57,6 +87,24 @@ func (engine *Engine) SetHandler(handler ScrapingHandlerFunc) *Engine {
 	return engine
 }
 
+func (engine *Engine) AddHandler(name string, handler ScrapingHandlerFunc) *Engine {
+	engine.handlers[name] = handler
+	return engine
+}
+
+func (engine *Engine) GetHandler(name string) ScrapingHandlerFunc {
+	return engine.handlers[name]
+}
+
+func (engine *Engine) SetRegistry(registry *Registry) *Engine {
+	engine.registry = registry
+	return engine
+}
+
+func (engine *Engine) Registry() *Registry {
+	return engine.registry
+}
+
 func (engine *Engine) IncrFinishedCounter() {
 	engine.finished += 1
 }
@@ -65,6 +113,78 @@ func (engine Engine) Done() bool {
 	return len(engine.scrapers) == engine.finished
 }
 
+func (engine *Engine) dispatch(proxy ScrapedItem) {
+	scraper := proxy.scraper
+
+	if scraper.HasRules() {
+		rule, ok := scraper.rules.ParseRule(proxy.Url)
+		if !ok {
+			return
+		}
+		_, params := rule.Pattern.Match(proxy.Url)
+		proxy = proxy.WithParams(params)
+		if rule.Handler != "" {
+			handler := engine.GetHandler(rule.Handler)
+			if handler == nil {
+				Logger().Warningf("Handler %s is not registered", rule.Handler)
+				return
+			}
+			handler(proxy, engine.chItems)
+			return
+		}
+	}
+
+	if engine.handler != nil {
+		engine.handler(proxy, engine.chItems)
+	}
+	if scraper.router.Dispatch(proxy, engine.chItems) {
+		return
+	}
+	if scraper.handler != nil {
+		scraper.handler(proxy, engine.chItems)
+	}
+}
+
+func (engine *Engine) extractItems(proxy ScrapedItem) {
+	defer SilentRecover("ITEMS")
+
+	if !proxy.scraper.Parses(proxy.Url) {
+		return
+	}
+
+	if extractor, ok := proxy.scraper.extractor.(ItemExtractable); ok {
+		items, err := extractor.ExtractItems(proxy)
+		if err != nil {
+			Logger().Warningf("Cannot extract items from %s: %s", proxy.Url, err)
+		}
+		for _, item := range items {
+			engine.processItem(item)
+		}
+	}
+
+	for _, definition := range proxy.scraper.items {
+		if !definition.Matches(proxy.Url) {
+			continue
+		}
+		items, err := definition.Extract(proxy)
+		if err != nil {
+			Logger().Warningf("Cannot extract %s from %s: %s", definition.Name, proxy.Url, err)
+			continue
+		}
+		for _, item := range items {
+			engine.processItem(item)
+		}
+	}
+}
+
+func (engine *Engine) processItem(item SaveableItem) {
+	for _, processed := range engine.pipeline.Process(item, engine.Meta) {
+		scraper := processed.Scraper()
+		engine.notifyExtensions(EVENT_SAVEABLE_EXTRACTED,
+			extensionParameters{scraper: scraper, item: processed})
+	}
+}
+
 func (engine *Engine) scrapingLoop() {
 	Logger().Info("Starting scraping loop")
 
@@ -74,24 +194,44 @@ func (engine *Engine) scrapingLoop() {
 			if !ok {
 				break
 			}
-			if engine.handler != nil {
-				engine.handler(proxy, engine.chItems)
-			}
-			if proxy.scraper.handler != nil {
-				proxy.scraper.handler(proxy, engine.chItems)
-			}
+			engine.dispatch(proxy)
+			engine.extractItems(proxy)
 		case item, ok := <-engine.chItems:
 			if !ok {
 				break
 			}
+			engine.processItem(item)
+		case reply := <-engine.chFlush:
+			engine.drain()
+			close(reply)
+		}
+	}
+}
 
-			scraper := item.Scraper()
-			engine.notifyExtensions(EVENT_SAVEABLE_EXTRACTED,
-				extensionParameters{scraper: scraper, item: item})
+func (engine *Engine) drain() {
+	for {
+		select {
+		case proxy := <-engine.chScraped:
+			engine.dispatch(proxy)
+			engine.extractItems(proxy)
+		case item := <-engine.chItems:
+			engine.processItem(item)
+		default:
+			return
 		}
 	}
 }
 
+func (engine *Engine) flush() {
+	if !engine.looping {
+		engine.drain()
+		return
+	}
+	reply := make(chan struct{})
+	engine.chFlush <- reply
+	<-reply
+}
+
 func (engine *Engine) startTCPServer() {
 	if engine.Config.TcpAddress != "" {
 		server := NewTCPServer(engine.Config.TcpAddress, engine)
@@ -123,36 +263,76 @@ func (engine *Engine) Start() {
 
 	go engine.startTCPServer()
 	go engine.startHTTPServer()
+	engine.looping = true
 	go engine.scrapingLoop()
 
 	for _, scraper := range engine.scrapers {
 		go scraper.Start()
 	}
 
-	select {
-	case <-engine.chDone:
-		if engine.Done() {
-			engine.wg.Wait()
-			Logger().Warning("All scrapers have stopped. Exiting...")
+	for {
+		select {
+		case <-engine.chDone:
+			if engine.Done() {
+				engine.wg.Wait()
+				engine.flush()
+				engine.shutdown()
+				Logger().Warning("All scrapers have stopped. Exiting...")
+				return
+			}
+		case sig := <-sigChan:
+			Logger().Warningf("Got signal: %s. Gracefully stopping...", sig)
+			engine.Stop()
 			return
 		}
-	case sig := <-sigChan:
-		Logger().Warningf("Got signal: %s. Gracefully stopping...", sig)
-		engine.Stop()
-		return
 	}
-
 }
 
 func (engine *Engine) Stop() {
 	engine.state = STATE_STOPPING
 	Logger().Info("Stopping engine")
 
+	var stopped []*Scraper
 	for _, scraper := range engine.scrapers {
-		scraper.Stop()
+		if scraper.halt() {
+			stopped = append(stopped, scraper)
+		}
 	}
 
 	engine.wg.Wait()
+	engine.flush()
+	for _, scraper := range stopped {
+		engine.notifyExtensions(EVENT_SCRAPER_CLOSED,
+			extensionParameters{scraper: scraper})
+	}
+	engine.shutdown()
+}
+
+func (engine *Engine) shutdown() {
+	engine.events.Wait()
+	engine.closeOnce.Do(func() {
+		engine.closeExtensions()
+		engine.pipeline.Close()
+		engine.closeDAO()
+	})
+}
+
+func (engine *Engine) closeDAO() {
+	if closer, ok := engine.dao.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			Logger().Warningf("Cannot close storage: %s", err)
+		}
+	}
+}
+
+func (engine *Engine) closeExtensions() {
+	for _, extension := range engine.extensions {
+		if closer, ok := extension.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				Logger().Warningf("Cannot close extension: %s", err)
+			}
+		}
+	}
 }
 
 func (engine *Engine) Cleanup() {
@@ -195,6 +375,20 @@ func (engine *Engine) UseMiddleware(middleware ...RequestMiddlewareFunc) *Engine
 	return engine
 }
 
+func (engine *Engine) UseResponseMiddleware(middleware ...ResponseMiddlewareFunc) *Engine {
+	engine.responseMiddleware = append(engine.responseMiddleware, middleware...)
+	return engine
+}
+
+func (engine *Engine) AddPipelineStages(stages ...*PipelineStage) *Engine {
+	engine.pipeline.AddStages(stages...)
+	return engine
+}
+
+func (engine *Engine) Pipeline() *Pipeline {
+	return engine.pipeline
+}
+
 func (engine *Engine) UseExtension(extensions ...Extension) *Engine {
 	engine.extensions = append(engine.extensions, extensions...)
 	return engine
@@ -207,40 +401,180 @@ func (engine *Engine) PrepareRequest(request *http.Request) *http.Request {
 	return request
 }
 
+func (engine *Engine) PrepareResponse(response *http.Response) *http.Response {
+	for _, middleware := range engine.responseMiddleware {
+		response = middleware(response)
+	}
+	return response
+}
+
+func (engine *Engine) useComponents(config *ScraperConfig) {
+	for _, component := range config.Middleware {
+		middleware, err := engine.registry.Middleware(component.Name, component.Options)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.UseMiddleware(middleware)
+		engine.Active.Add(COMPONENT_MIDDLEWARE, component.Name)
+	}
+	for _, component := range config.ResponseMiddleware {
+		middleware, err := engine.registry.ResponseMiddleware(component.Name, component.Options)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.UseResponseMiddleware(middleware)
+		engine.Active.Add(COMPONENT_RESPONSE_MIDDLEWARE, component.Name)
+	}
+	for _, component := range config.Extensions {
+		extension, err := engine.registry.Extension(component.Name, component.Options)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.UseExtension(extension)
+		engine.Active.Add(COMPONENT_EXTENSION, component.Name)
+	}
+	for _, stageData := range config.Pipeline {
+		stage, err := NewPipelineStageFromConfig(engine.registry, stageData)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.AddPipelineStages(stage)
+		engine.Active.Add(COMPONENT_STAGE, stageData.Stage)
+	}
+	for _, component := range config.Handlers {
+		engine.AddHandler(component.Name, engine.mustResolveHandler(component.Name, component.Options))
+	}
+}
+
+func (engine *Engine) mustResolveHandler(name string, options ComponentOptions) ScrapingHandlerFunc {
+	handler, err := engine.registry.Handler(name, options)
+	if err != nil {
+		Logger().Fatalf("Invalid configuration: %s", err)
+	}
+	engine.Active.Add(COMPONENT_HANDLER, name)
+	return handler
+}
+
+func (engine *Engine) resolveRuleHandler(name string) {
+	if name == "" {
+		return
+	}
+	if _, ok := engine.handlers[name]; ok {
+		engine.Active.Add(COMPONENT_HANDLER, name)
+		return
+	}
+	if handler, err := engine.registry.Handler(name, ComponentOptions{}); err == nil {
+		engine.AddHandler(name, handler)
+		engine.Active.Add(COMPONENT_HANDLER, name)
+	}
+}
+
 func (engine *Engine) FromConfig(config *ScraperConfig) *Engine {
 	engine.Config = config
+	engine.useComponents(config)
+
+	if config.Storage != "" {
+		dao, err := NewDAO(config.Storage)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.SetDAO(dao)
+	}
 
 	for _, configData := range config.Scrapers {
-		extractor := defaultExtractor()
-		switch configData.Extractor {
-		case "link":
-			extractor = &LinkExtractor{}
-		default:
-			break
+		extractorName := configData.Extractor
+		if extractorName == "" {
+			extractorName = "link"
+		}
+		options := configData.ExtractorOptions
+		if len(options) == 0 {
+			switch extractorName {
+			case "json":
+				options = ComponentOptions{"paths": configData.JsonPaths}
+			case "sitemap":
+				options = NewComponentOptions(configData.Sitemap)
+			case "feed":
+				options = NewComponentOptions(configData.Feed)
+			}
 		}
+		extractor, err := engine.registry.Extractor(extractorName, options)
+		if err != nil {
+			Logger().Fatalf("Invalid configuration: %s", err)
+		}
+		engine.Active.Add(COMPONENT_EXTRACTOR, extractorName)
+
 		params := ScraperParams{
-			Extractor:    extractor,
-			Name:         configData.Name,
-			Url:          configData.Url,
-			RequestLimit: configData.RequestLimit,
+			Extractor:       extractor,
+			Name:            configData.Name,
+			Url:             configData.Url,
+			RequestLimit:    configData.RequestLimit,
+			MaxDepth:        configData.MaxDepth,
+			DepthPriority:   configData.DepthPriority,
+			HonorRobotsMeta: configData.HonorRobotsMeta,
 		}
 		scraper := NewScraper(params)
-		for _, patternData := range configData.Patterns {
-			pattern := NewURLPattern(patternData.Type, patternData.Pattern)
-			scraper.AddPatterns(pattern)
+		patterns := make([]URLPattern, len(configData.Patterns))
+		for index, patternData := range configData.Patterns {
+			patterns[index] = mustCompileURLPattern(patternData.Type, patternData.Pattern, patternData.Exclude)
+		}
+		scraper.AddPatterns(patterns...)
+		for _, ruleData := range configData.Rules {
+			pattern := mustCompileURLPattern(ruleData.Type, ruleData.Pattern, false)
+			rule := NewRule(pattern, ruleData.Follow, ruleData.Parse, ruleData.Handler, ruleData.Deny)
+			scraper.AddRules(rule)
+			engine.resolveRuleHandler(ruleData.Handler)
+		}
+		if configData.Handler != "" {
+			scraper.SetHandler(engine.mustResolveHandler(configData.Handler, configData.HandlerOptions))
+		}
+		if seedable, ok := extractor.(Seedable); ok {
+			scraper.AddSeeds(seedable.Seeds(scraper)...)
+		}
+		if configData.Pagination.Enabled() {
+			paginator, err := NewPaginator(configData.Pagination)
+			if err != nil {
+				Logger().Fatalf("Invalid configuration: %s", err)
+			}
+			scraper.SetPaginator(paginator)
+		}
+		for _, itemData := range configData.Items {
+			definition, err := NewItemDefinitionFromConfig(itemData)
+			if err != nil {
+				Logger().Fatalf("Invalid configuration: %s", err)
+			}
+			scraper.AddItemDefinitions(definition)
 		}
-		Logger().Debugf("Defined following url patterns: %s", scraper.urlPatterns)
+		Logger().Debugf("Defined following url patterns: %s", scraper.urlPatterns.Patterns())
+		Logger().Debugf("Defined following rules: %s", scraper.rules)
 		engine.AddScrapers(scraper)
 	}
 
 	return engine
 }
 
+func mustCompileURLPattern(kind string, pattern string, exclude bool) URLPattern {
+	instance, err := CompileURLPattern(kind, pattern, exclude)
+	if err != nil {
+		Logger().Fatalf("Invalid configuration: %s", err)
+	}
+	return instance
+}
+
+func (engine *Engine) SetDAO(dao DAO) *Engine {
+	engine.dao = dao
+	return engine
+}
+
 func GetDAO(engine *Engine) DAO {
-	if engine.Config.RedisAddress != "" {
-		return NewRedisDao(engine.Config.RedisAddress)
+	if engine == nil {
+		return nil
 	}
-	return nil
+	engine.daoOnce.Do(func() {
+		if engine.dao == nil && engine.Config.RedisAddress != "" {
+			engine.dao = NewRedisDao(engine.Config.RedisAddress)
+		}
+	})
+	return engine.dao
 }
 
 func NewEngine() (r *Engine) {
@@ -251,9 +585,16 @@ func NewEngine() (r *Engine) {
 		limitCrawl: 10000,
 		limitFail:  500,
 		finished:   0,
+		handlers:   make(map[string]ScrapingHandlerFunc),
+		registry:   DefaultRegistry(),
+		Active:     NewActiveComponents(),
+		pipeline:   NewPipeline(),
 		chDone:     make(chan struct{}),
 		chScraped:  make(chan ScrapedItem, 100),
 		chItems:    make(chan SaveableItem, 250),
+		chEvents:   make(chan extensionEvent, 500),
+		chFlush:    make(chan chan struct{}),
 	}
+	go r.eventLoop()
 	return
 }
@@ -0,0 +1,106 @@
+package gotana
+
+import (
+	"sync"
+	"testing"
+)
+
+type recordingExtension struct {
+	mutex   sync.Mutex
+	started []string
+	stopped []string
+	items   []SaveableItem
+}
+
+func (extension *recordingExtension) ScraperStarted(scraper *Scraper) {
+	extension.mutex.Lock()
+	defer extension.mutex.Unlock()
+	extension.started = append(extension.started, scraper.Name)
+}
+
+func (extension *recordingExtension) ScraperStopped(scraper *Scraper) {
+	extension.mutex.Lock()
+	defer extension.mutex.Unlock()
+	extension.stopped = append(extension.stopped, scraper.Name)
+}
+
+func (extension *recordingExtension) ItemScraped(scraper *Scraper, item SaveableItem) {
+	extension.mutex.Lock()
+	defer extension.mutex.Unlock()
+	extension.items = append(extension.items, item)
+}
+
+func (extension *recordingExtension) Items() []SaveableItem {
+	extension.mutex.Lock()
+	defer extension.mutex.Unlock()
+	return append([]SaveableItem{}, extension.items...)
+}
+
+func newRecordingEngine(scrapers ...*Scraper) (*Engine, *recordingExtension) {
+	recorder := &recordingExtension{}
+	engine := NewEngine()
+	engine.extensions = append(engine.extensions, recorder)
+	engine.AddScrapers(scrapers...)
+	return engine, recorder
+}
+
+func scrapedPage(scraper *Scraper, url string, body string) ScrapedItem {
+	return ScrapedItem{Url: url, FinalUrl: url, BodyBytes: []byte(body), scraper: scraper}
+}
+
+func TestEngineExtractsItemsFromParsedPages(t *testing.T) {
+	const page = `<ul><li><span>first</span></li><li><span>second</span></li></ul>`
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		expected map[string]int
+	}{
+		{
+			"no rules",
+			nil,
+			map[string]int{"http://example.com/list": 2, "http://example.com/item/1": 2},
+		},
+		{
+			"follow only",
+			[]Rule{NewRule(NewURLPattern(TYPE_CONTAINS, "/"), true, false, "", false)},
+			map[string]int{"http://example.com/list": 0, "http://example.com/item/1": 0},
+		},
+		{
+			"parse only",
+			[]Rule{NewRule(NewURLPattern(TYPE_CONTAINS, "/item/"), false, true, "", false)},
+			map[string]int{"http://example.com/list": 0, "http://example.com/item/1": 2},
+		},
+		{
+			"deny",
+			[]Rule{
+				NewRule(NewURLPattern(TYPE_CONTAINS, "/"), true, true, "", false),
+				NewRule(NewURLPattern(TYPE_CONTAINS, "/item/"), false, false, "", true),
+			},
+			map[string]int{"http://example.com/list": 2, "http://example.com/item/1": 0},
+		},
+	}
+
+	for _, test := range tests {
+		definition, err := NewItemDefinitionFromConfig(ItemConfig{
+			Name:      "entry",
+			Container: "li",
+			Fields:    []ItemFieldConfig{{Name: "title", Selector: "span"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		scraper := NewScraper(ScraperParams{Name: "example", Url: "http://example.com"})
+		scraper.AddRules(test.rules...).AddItemDefinitions(definition)
+		engine, recorder := newRecordingEngine(scraper)
+
+		for url, expected := range test.expected {
+			recorder.items = nil
+			engine.extractItems(scrapedPage(scraper, url, page))
+			engine.events.Wait()
+			if count := len(recorder.Items()); count != expected {
+				t.Errorf("%s: %d items extracted from %s, expected %d", test.name, count, url, expected)
+			}
+		}
+	}
+}
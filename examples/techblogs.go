@@ -4,25 +4,20 @@ import (
 	//"github.com/PuerkitoBio/goquery"
 	"github.com/PuerkitoBio/goquery"
 	"gotana"
-	"strings"
 )
 
 func ParsePornHub(proxy gotana.ScrapedItem, items chan<- gotana.SaveableItem) {
 	defer gotana.SilentRecover("ParsePornHub")
 
-	if strings.Contains(proxy.Url, "/insights/") {
-
-		document, err := proxy.HTMLDocument()
-		if err != nil {
-			gotana.Logger().Error(err.Error())
-			return
-		}
-		title := document.Find("title").First().Text()
-
-		if title != "" {
-			gotana.Logger().Noticef("%s --> %s", proxy.Url, title)
-		}
+	document, err := proxy.HTMLDocument()
+	if err != nil {
+		gotana.Logger().Error(err.Error())
+		return
+	}
+	title := document.Find("title").First().Text()
 
+	if title != "" {
+		gotana.Logger().Noticef("%s [%s] --> %s", proxy.Url, proxy.Param("slug"), title)
 	}
 }
 
@@ -66,7 +61,7 @@ func main() {
 	engine.FromConfig(config)
 	engine.UseMiddleware(gotana.RandomUserAgentMiddleware)
 
-	engine.GetScraper("pornhub").SetHandler(ParsePornHub)
+	engine.GetScraper("pornhub").Route(gotana.NewURLPattern(gotana.TYPE_REGEXP, "/insights/(?P<slug>.*)"), ParsePornHub)
 	engine.GetScraper("spotify").SetHandler(ParseSpotify)
 	engine.GetScraper("techcrunch").SetHandler(ParseTechChrunch)
 
@@ -8,8 +8,8 @@ import (
 
 type Issue struct {
 	gotana.ScraperMixin
-	Title string
-	Href  string
+	Title string `gotana:"css=a"`
+	Href  string `gotana:"css=a,attr=href"`
 }
 
 func (item Issue) Validate() bool {
@@ -29,10 +29,11 @@ func IssueHandler(proxy gotana.ScrapedItem, items chan<- gotana.SaveableItem) {
 			gotana.Logger().Error(err.Error())
 			return
 		}
-		document.Find(".item-link-title a").Each(func(i int, s *goquery.Selection) {
-			issue := Issue{
-				Title: s.Text(),
-				Href:  s.AttrOr("href", ""),
+		document.Find(".item-link-title").Each(func(i int, s *goquery.Selection) {
+			issue := Issue{}
+			if err := gotana.Populate(&issue, s); err != nil {
+				gotana.Logger().Warning(err.Error())
+				return
 			}
 			issue.Proxy = proxy
 			items <- issue
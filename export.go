@@ -0,0 +1,679 @@
+package gotana
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EXPORT_FORMAT_JSON  = "json"
+	EXPORT_FORMAT_JSONL = "jsonl"
+	EXPORT_FORMAT_CSV   = "csv"
+	EXPORT_FORMAT_XML   = "xml"
+	COMPRESSION_GZIP    = "gzip"
+	COMPRESSION_ZSTD    = "zstd"
+)
+
+var compressionExtensions = map[string]string{
+	"":               "",
+	COMPRESSION_GZIP: ".gz",
+	COMPRESSION_ZSTD: ".zst",
+}
+
+var xmlInvalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+type FeedWriter interface {
+	WriteItem(item SaveableItem) error
+	Close() error
+}
+
+type FeedWriterFactory func(w io.Writer, fields []string) FeedWriter
+
+var feedFormats = map[string]FeedWriterFactory{}
+
+func RegisterFeedFormat(name string, factory FeedWriterFactory) {
+	feedFormats[name] = factory
+}
+
+func NewFeedWriter(format string, w io.Writer, fields []string) (FeedWriter, error) {
+	factory, ok := feedFormats[strings.ToLower(format)]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown export format: %s", format))
+	}
+	return factory(w, fields), nil
+}
+
+func exportRecord(item SaveableItem, fields []string) (map[string]interface{}, error) {
+	data, err := item.RecordData()
+	if err != nil {
+		return nil, err
+	}
+	record := map[string]interface{}{}
+	if err = json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return record, nil
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		projected[field] = record[field]
+	}
+	return projected, nil
+}
+
+func exportData(item SaveableItem, fields []string) ([]byte, error) {
+	if len(fields) == 0 {
+		return item.RecordData()
+	}
+	record, err := exportRecord(item, fields)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(record)
+}
+
+func sortedFields(record map[string]interface{}) []string {
+	fields := make([]string, 0, len(record))
+	for field := range record {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+type JSONLinesWriter struct {
+	w      io.Writer
+	fields []string
+}
+
+func (writer *JSONLinesWriter) WriteItem(item SaveableItem) error {
+	data, err := exportData(item, writer.fields)
+	if err != nil {
+		return err
+	}
+	_, err = writer.w.Write(append(data, '\n'))
+	return err
+}
+
+func (writer *JSONLinesWriter) Close() error {
+	return nil
+}
+
+type JSONArrayWriter struct {
+	w      io.Writer
+	fields []string
+	count  int
+}
+
+func (writer *JSONArrayWriter) WriteItem(item SaveableItem) error {
+	data, err := exportData(item, writer.fields)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if writer.count == 0 {
+		prefix = "[\n"
+	}
+	writer.count += 1
+	_, err = writer.w.Write(append([]byte(prefix), data...))
+	return err
+}
+
+func (writer *JSONArrayWriter) Close() error {
+	footer := "\n]\n"
+	if writer.count == 0 {
+		footer = "[]\n"
+	}
+	_, err := io.WriteString(writer.w, footer)
+	return err
+}
+
+type CSVWriter struct {
+	w        *csv.Writer
+	fields   []string
+	header   bool
+	inferred bool
+	columns  map[string]bool
+}
+
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func (writer *CSVWriter) WriteItem(item SaveableItem) error {
+	fields := writer.fields
+	if writer.inferred {
+		fields = nil
+	}
+	record, err := exportRecord(item, fields)
+	if err != nil {
+		return err
+	}
+
+	if !writer.header {
+		if len(writer.fields) == 0 {
+			writer.fields = sortedFields(record)
+			writer.inferred = true
+		}
+		if err = writer.w.Write(writer.fields); err != nil {
+			return err
+		}
+		writer.header = true
+		writer.columns = make(map[string]bool)
+		for _, field := range writer.fields {
+			writer.columns[field] = true
+		}
+	}
+
+	for _, field := range sortedFields(record) {
+		if !writer.columns[field] {
+			Logger().Warningf("CSV export has no column for field %s. Set fields to include it", field)
+			writer.columns[field] = true
+		}
+	}
+
+	row := make([]string, len(writer.fields))
+	for index, field := range writer.fields {
+		row[index] = csvValue(record[field])
+	}
+	return writer.w.Write(row)
+}
+
+func (writer *CSVWriter) Close() error {
+	writer.w.Flush()
+	return writer.w.Error()
+}
+
+type XMLWriter struct {
+	w      io.Writer
+	fields []string
+	count  int
+}
+
+func xmlName(name string) string {
+	name = xmlInvalidNameChars.ReplaceAllString(name, "_")
+	if name == "" || strings.ContainsAny(name[:1], "0123456789.-") {
+		name = "_" + name
+	}
+	return name
+}
+
+func writeXMLValue(w io.Writer, name string, value interface{}) (err error) {
+	name = xmlName(name)
+	switch v := value.(type) {
+	case nil:
+		_, err = fmt.Fprintf(w, "<%s/>", name)
+		return
+	case []interface{}:
+		if _, err = fmt.Fprintf(w, "<%s>", name); err != nil {
+			return
+		}
+		for _, element := range v {
+			if err = writeXMLValue(w, "value", element); err != nil {
+				return
+			}
+		}
+	case map[string]interface{}:
+		if _, err = fmt.Fprintf(w, "<%s>", name); err != nil {
+			return
+		}
+		for _, field := range sortedFields(v) {
+			if err = writeXMLValue(w, field, v[field]); err != nil {
+				return
+			}
+		}
+	default:
+		if _, err = fmt.Fprintf(w, "<%s>", name); err != nil {
+			return
+		}
+		if err = xml.EscapeText(w, []byte(csvValue(v))); err != nil {
+			return
+		}
+	}
+	_, err = fmt.Fprintf(w, "</%s>", name)
+	return
+}
+
+func (writer *XMLWriter) WriteItem(item SaveableItem) error {
+	record, err := exportRecord(item, writer.fields)
+	if err != nil {
+		return err
+	}
+
+	if writer.count == 0 {
+		if _, err = io.WriteString(writer.w, xml.Header+"<items>\n"); err != nil {
+			return err
+		}
+	}
+	writer.count += 1
+
+	fields := writer.fields
+	if len(fields) == 0 {
+		fields = sortedFields(record)
+	}
+	if _, err = io.WriteString(writer.w, "  <item>"); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if err = writeXMLValue(writer.w, field, record[field]); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(writer.w, "</item>\n")
+	return err
+}
+
+func (writer *XMLWriter) Close() error {
+	footer := "</items>\n"
+	if writer.count == 0 {
+		footer = xml.Header + "<items>\n" + footer
+	}
+	_, err := io.WriteString(writer.w, footer)
+	return err
+}
+
+type ExportRotationConfig struct {
+	Items    int
+	Size     int64
+	Interval string
+}
+
+type FeedExportConfig struct {
+	Format      string
+	Path        string `required:"true"`
+	Fields      []string
+	Scrapers    []string
+	Compression string
+	Rotate      ExportRotationConfig
+	Storage     ExportStorageConfig
+	Manifest    string
+}
+
+type ManifestEntry struct {
+	Path        string    `json:"path"`
+	Location    string    `json:"location"`
+	Scraper     string    `json:"scraper,omitempty"`
+	Part        int       `json:"part"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression,omitempty"`
+	Items       int       `json:"items"`
+	Bytes       int64     `json:"bytes"`
+	Started     time.Time `json:"started"`
+	Finished    time.Time `json:"finished"`
+}
+
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (writer *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = writer.w.Write(p)
+	writer.count += int64(n)
+	return
+}
+
+func NewCompressor(compression string, w io.Writer) (io.WriteCloser, error) {
+	switch strings.ToLower(compression) {
+	case "":
+		return nil, nil
+	case COMPRESSION_GZIP:
+		return gzip.NewWriter(w), nil
+	case COMPRESSION_ZSTD:
+		encoder, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return encoder, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown compression: %s", compression))
+}
+
+type feedFile struct {
+	path       string
+	scraper    string
+	part       int
+	opened     time.Time
+	items      int
+	file       *os.File
+	counter    *countingWriter
+	compressor io.WriteCloser
+	buffer     *bufio.Writer
+	writer     FeedWriter
+}
+
+// With compression only bytes already emitted by the compressor are counted,
+// data held in its internal buffers is not known until it is flushed.
+func (feed *feedFile) Size() int64 {
+	if feed.compressor != nil {
+		return feed.counter.count
+	}
+	return feed.counter.count + int64(feed.buffer.Buffered())
+}
+
+func (feed *feedFile) Close() error {
+	err := feed.writer.Close()
+	if flushErr := feed.buffer.Flush(); err == nil {
+		err = flushErr
+	}
+	if feed.compressor != nil {
+		if closeErr := feed.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := feed.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type FeedExporter struct {
+	Config   FeedExportConfig
+	storage  ExportStorage
+	interval time.Duration
+	mutex    sync.Mutex
+	scrapers map[string]bool
+	files    map[string]*feedFile
+	parts    map[string]int
+	manifest []ManifestEntry
+	project  string
+	closed   bool
+}
+
+func (exporter *FeedExporter) key(scraper *Scraper) string {
+	if strings.Contains(exporter.Config.Path, "{scraper}") {
+		return scraper.Name
+	}
+	return ""
+}
+
+func (exporter *FeedExporter) rotates() bool {
+	rotation := exporter.Config.Rotate
+	return rotation.Items > 0 || rotation.Size > 0 || exporter.interval > 0
+}
+
+func (exporter *FeedExporter) expand(key string, part int) string {
+	path := exporter.Config.Path
+	if exporter.rotates() && !strings.Contains(path, "{part}") {
+		base := strings.TrimSuffix(path, compressionExtensions[exporter.Config.Compression])
+		extension := filepath.Ext(base)
+		path = strings.TrimSuffix(base, extension) + "-{part}" + extension + path[len(base):]
+	}
+	path = strings.Replace(path, "{part}", fmt.Sprintf("%05d", part), -1)
+	return ExpandPath(path, exporter.project, key)
+}
+
+func (exporter *FeedExporter) open(scraper *Scraper) (feed *feedFile, err error) {
+	key := exporter.key(scraper)
+	if feed, ok := exporter.files[key]; ok {
+		return feed, nil
+	}
+
+	if scraper.engine != nil && scraper.engine.Config != nil {
+		exporter.project = scraper.engine.Config.Project
+	}
+	exporter.parts[key] += 1
+	part := exporter.parts[key]
+	path := exporter.expand(key, part)
+
+	file, err := exporter.storage.TempFile(path)
+	if err != nil {
+		return
+	}
+
+	feed = &feedFile{path: path, scraper: key, part: part, opened: time.Now(), file: file}
+	feed.counter = &countingWriter{w: file}
+	var output io.Writer = feed.counter
+	if feed.compressor, err = NewCompressor(exporter.Config.Compression, feed.counter); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if feed.compressor != nil {
+		output = feed.compressor
+	}
+	feed.buffer = bufio.NewWriter(output)
+	if feed.writer, err = NewFeedWriter(exporter.Config.Format, feed.buffer, exporter.Config.Fields); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	exporter.files[key] = feed
+	Logger().Infof("Exporting items of %s to %s", scraper.Name, path)
+	return
+}
+
+func (exporter *FeedExporter) finalize(key string, feed *feedFile) error {
+	delete(exporter.files, key)
+
+	err := feed.Close()
+	if err != nil {
+		os.Remove(feed.file.Name())
+		return err
+	}
+
+	location, err := exporter.storage.Store(feed.file.Name(), feed.path)
+	if err != nil {
+		return err
+	}
+	Logger().Infof("Exported %d items to %s", feed.items, location)
+
+	exporter.manifest = append(exporter.manifest, ManifestEntry{
+		Path:        feed.path,
+		Location:    location,
+		Scraper:     feed.scraper,
+		Part:        feed.part,
+		Format:      exporter.Config.Format,
+		Compression: exporter.Config.Compression,
+		Items:       feed.items,
+		Bytes:       feed.counter.count,
+		Started:     feed.opened,
+		Finished:    time.Now(),
+	})
+	return exporter.writeManifest()
+}
+
+func (exporter *FeedExporter) writeManifest() error {
+	if exporter.Config.Manifest == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(exporter.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := ExpandPath(exporter.Config.Manifest, exporter.project, "")
+	file, err := exporter.storage.TempFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	_, err = exporter.storage.Store(file.Name(), path)
+	return err
+}
+
+func (exporter *FeedExporter) Manifest() []ManifestEntry {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return append([]ManifestEntry{}, exporter.manifest...)
+}
+
+func (exporter *FeedExporter) shouldRotate(feed *feedFile) bool {
+	rotation := exporter.Config.Rotate
+	return (rotation.Items > 0 && feed.items >= rotation.Items) ||
+		(rotation.Size > 0 && feed.Size() >= rotation.Size) ||
+		(exporter.interval > 0 && time.Since(feed.opened) >= exporter.interval)
+}
+
+func (exporter *FeedExporter) ScraperStarted(scraper *Scraper) {
+
+}
+
+func (exporter *FeedExporter) ScraperStopped(scraper *Scraper) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if feed, ok := exporter.files[exporter.key(scraper)]; ok {
+		feed.buffer.Flush()
+	}
+}
+
+func (exporter *FeedExporter) ItemScraped(scraper *Scraper, item SaveableItem) {
+	if scraper == nil || (len(exporter.scrapers) > 0 && !exporter.scrapers[scraper.Name]) {
+		return
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	if exporter.closed {
+		Logger().Warningf("Exporter %s is closed. Skipping item of %s", exporter.Config.Path, scraper.Name)
+		return
+	}
+
+	key := exporter.key(scraper)
+	if feed, ok := exporter.files[key]; ok && exporter.interval > 0 && time.Since(feed.opened) >= exporter.interval {
+		if err := exporter.finalize(key, feed); err != nil {
+			Logger().Warningf("Cannot finalize export file %s: %s", feed.path, err)
+		}
+	}
+
+	feed, err := exporter.open(scraper)
+	if err != nil {
+		Logger().Warningf("Cannot open export file: %s", err)
+		return
+	}
+	if err = feed.writer.WriteItem(item); err != nil {
+		Logger().Warningf("Cannot export item to %s: %s", feed.path, err)
+		return
+	}
+	feed.items += 1
+
+	if exporter.shouldRotate(feed) {
+		if err = exporter.finalize(key, feed); err != nil {
+			Logger().Warningf("Cannot finalize export file %s: %s", feed.path, err)
+		}
+	}
+}
+
+func (exporter *FeedExporter) Close() (err error) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.closed = true
+	for key, feed := range exporter.files {
+		if closeErr := exporter.finalize(key, feed); closeErr != nil {
+			Logger().Warningf("Cannot finalize export file %s: %s", feed.path, closeErr)
+			err = closeErr
+		}
+	}
+	return
+}
+
+func (exporter *FeedExporter) String() string {
+	return fmt.Sprintf("<FeedExporter: %s>. Format: %s", exporter.Config.Path, exporter.Config.Format)
+}
+
+func NewFeedExporter(config FeedExportConfig) (exporter *FeedExporter, err error) {
+	if config.Path == "" {
+		return nil, errors.New("Feed exporter requires path")
+	}
+
+	config.Compression = strings.ToLower(config.Compression)
+	if config.Compression == "" {
+		for compression, extension := range compressionExtensions {
+			if extension != "" && strings.HasSuffix(config.Path, extension) {
+				config.Compression = compression
+			}
+		}
+	}
+	if _, ok := compressionExtensions[config.Compression]; !ok && config.Compression != "" {
+		return nil, errors.New(fmt.Sprintf("Unknown compression: %s", config.Compression))
+	}
+	if extension := compressionExtensions[config.Compression]; !strings.HasSuffix(config.Path, extension) {
+		config.Path += extension
+	}
+
+	if config.Format == "" {
+		base := strings.TrimSuffix(config.Path, compressionExtensions[config.Compression])
+		config.Format = strings.TrimPrefix(filepath.Ext(base), ".")
+	}
+	config.Format = strings.ToLower(config.Format)
+	if _, ok := feedFormats[config.Format]; !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown export format: %s", config.Format))
+	}
+
+	storage, err := NewExportStorage(config.Storage)
+	if err != nil {
+		return
+	}
+
+	exporter = &FeedExporter{
+		Config:   config,
+		storage:  storage,
+		scrapers: make(map[string]bool),
+		files:    make(map[string]*feedFile),
+		parts:    make(map[string]int),
+	}
+	if config.Rotate.Interval != "" {
+		if exporter.interval, err = time.ParseDuration(config.Rotate.Interval); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range config.Scrapers {
+		exporter.scrapers[name] = true
+	}
+	return
+}
+
+func init() {
+	RegisterFeedFormat(EXPORT_FORMAT_JSON, func(w io.Writer, fields []string) FeedWriter {
+		return &JSONArrayWriter{w: w, fields: fields}
+	})
+	RegisterFeedFormat(EXPORT_FORMAT_JSONL, func(w io.Writer, fields []string) FeedWriter {
+		return &JSONLinesWriter{w: w, fields: fields}
+	})
+	RegisterFeedFormat(EXPORT_FORMAT_CSV, func(w io.Writer, fields []string) FeedWriter {
+		return &CSVWriter{w: csv.NewWriter(w), fields: fields}
+	})
+	RegisterFeedFormat(EXPORT_FORMAT_XML, func(w io.Writer, fields []string) FeedWriter {
+		return &XMLWriter{w: w, fields: fields}
+	})
+
+	RegisterExtension("export", func(options ComponentOptions) (Extension, error) {
+		config := FeedExportConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewFeedExporter(config)
+	})
+}
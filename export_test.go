@@ -0,0 +1,199 @@
+package gotana
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func exportItem(scraper *Scraper, title string) GenericItem {
+	item := NewGenericItem("product", ScrapedItem{scraper: scraper})
+	item.Fields["title"] = title
+	item.Fields["price"] = 3.5
+	item.Fields["tags"] = []interface{}{"x", "y"}
+	return item
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	writer.Close()
+	output, _ := ioutil.ReadAll(reader)
+	return string(output)
+}
+
+func TestFeedWriters(t *testing.T) {
+	tests := []struct {
+		format   string
+		fields   []string
+		items    []string
+		expected string
+	}{
+		{EXPORT_FORMAT_JSON, nil, nil, "[]\n"},
+		{EXPORT_FORMAT_JSON, []string{"title"}, []string{"A", "B"}, "[\n{\"title\":\"A\"},\n{\"title\":\"B\"}\n]\n"},
+		{EXPORT_FORMAT_JSONL, nil, []string{"A"}, "{\"price\":3.5,\"tags\":[\"x\",\"y\"],\"title\":\"A\"}\n"},
+		{EXPORT_FORMAT_JSONL, []string{"title", "missing"}, []string{"A"}, "{\"missing\":null,\"title\":\"A\"}\n"},
+		{EXPORT_FORMAT_CSV, nil, []string{"A, B", "C"}, "price,tags,title\n3.5,\"[\"\"x\"\",\"\"y\"\"]\",\"A, B\"\n3.5,\"[\"\"x\"\",\"\"y\"\"]\",C\n"},
+		{EXPORT_FORMAT_CSV, []string{"title", "missing"}, []string{"A"}, "title,missing\nA,\n"},
+		{EXPORT_FORMAT_XML, nil, nil, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<items>\n</items>\n"},
+		{EXPORT_FORMAT_XML, nil, []string{"A & B"},
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<items>\n" +
+				"  <item><price>3.5</price><tags><value>x</value><value>y</value></tags><title>A &amp; B</title></item>\n</items>\n"},
+		{"XML", []string{"1st field", "title"}, []string{"A"},
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<items>\n  <item><_1st_field/><title>A</title></item>\n</items>\n"},
+	}
+
+	for _, test := range tests {
+		buffer := &bytes.Buffer{}
+		writer, err := NewFeedWriter(test.format, buffer, test.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, title := range test.items {
+			if err := writer.WriteItem(exportItem(nil, title)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != test.expected {
+			t.Errorf("%s writer with fields %v wrote %q, expected %q", test.format, test.fields, buffer.String(), test.expected)
+		}
+	}
+
+	if _, err := NewFeedWriter("yaml", &bytes.Buffer{}, nil); err == nil {
+		t.Error("NewFeedWriter accepted unknown format")
+	}
+}
+
+func TestCSVWriterWarnsAboutMissingColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		warnings []string
+	}{
+		{"inferred header", nil, []string{"extra"}},
+		{"configured fields", []string{"title", "price", "tags"}, nil},
+		{"partial fields", []string{"title"}, nil},
+	}
+
+	for _, test := range tests {
+		buffer := &bytes.Buffer{}
+		writer, _ := NewFeedWriter(EXPORT_FORMAT_CSV, buffer, test.fields)
+		output := captureStdout(t, func() {
+			writer.WriteItem(exportItem(nil, "A"))
+			item := exportItem(nil, "B")
+			item.Fields["extra"] = 1
+			writer.WriteItem(item)
+			writer.WriteItem(item)
+			writer.Close()
+		})
+
+		warnings := strings.Count(output, "CSV export has no column")
+		if warnings != len(test.warnings) {
+			t.Errorf("%s: logged %d warnings, expected %v:\n%s", test.name, warnings, test.warnings, output)
+		}
+		for _, field := range test.warnings {
+			if !strings.Contains(output, "no column for field "+field) {
+				t.Errorf("%s: no warning about %s in %q", test.name, field, output)
+			}
+		}
+		if header := strings.SplitN(buffer.String(), "\n", 2)[0]; strings.Contains(header, "extra") {
+			t.Errorf("%s: header %q changed after first item", test.name, header)
+		}
+	}
+}
+
+func TestNewFeedExporter(t *testing.T) {
+	tests := []struct {
+		config      FeedExportConfig
+		format      string
+		path        string
+		compression string
+	}{
+		{FeedExportConfig{Path: "items.jsonl"}, EXPORT_FORMAT_JSONL, "items.jsonl", ""},
+		{FeedExportConfig{Path: "items.CSV"}, EXPORT_FORMAT_CSV, "items.CSV", ""},
+		{FeedExportConfig{Path: "items.out", Format: "XML"}, EXPORT_FORMAT_XML, "items.out", ""},
+		{FeedExportConfig{Path: "items.json.gz"}, EXPORT_FORMAT_JSON, "items.json.gz", COMPRESSION_GZIP},
+		{FeedExportConfig{Path: "items.json", Compression: "ZSTD"}, EXPORT_FORMAT_JSON, "items.json.zst", COMPRESSION_ZSTD},
+	}
+	for _, test := range tests {
+		exporter, err := NewFeedExporter(test.config)
+		if err != nil {
+			t.Errorf("NewFeedExporter(%+v) failed: %s", test.config, err)
+			continue
+		}
+		if config := exporter.Config; config.Format != test.format || config.Path != test.path || config.Compression != test.compression {
+			t.Errorf("NewFeedExporter(%+v) configured %s %s %s, expected %s %s %s", test.config,
+				config.Format, config.Path, config.Compression, test.format, test.path, test.compression)
+		}
+	}
+
+	for _, config := range []FeedExportConfig{
+		{},
+		{Path: "items.txt"},
+		{Path: "items.json", Compression: "bzip2"},
+		{Path: "items.json", Rotate: ExportRotationConfig{Interval: "often"}},
+	} {
+		if _, err := NewFeedExporter(config); err == nil {
+			t.Errorf("NewFeedExporter(%+v) succeeded, expected error", config)
+		}
+	}
+}
+
+func TestFeedExporter(t *testing.T) {
+	dir := t.TempDir()
+	engine := NewEngine()
+	engine.Config.Project = "proj"
+	a := NewScraper(ScraperParams{Name: "a", Url: "http://a.com"})
+	b := NewScraper(ScraperParams{Name: "b", Url: "http://b.com"})
+	engine.AddScrapers(a, b)
+
+	tests := []struct {
+		options ComponentOptions
+		files   map[string]string
+	}{
+		{ComponentOptions{"path": filepath.Join(dir, "{project}", "{scraper}.jsonl"), "fields": []interface{}{"title"}},
+			map[string]string{"proj/a.jsonl": "{\"title\":\"A\"}\n", "proj/b.jsonl": "{\"title\":\"B\"}\n"}},
+		{ComponentOptions{"path": filepath.Join(dir, "all.csv"), "fields": []interface{}{"title"}},
+			map[string]string{"all.csv": "title\nA\nB\n"}},
+		{ComponentOptions{"path": filepath.Join(dir, "only-b.json"), "fields": []interface{}{"title"}, "scrapers": []interface{}{"b"}},
+			map[string]string{"only-b.json": "[\n{\"title\":\"B\"}\n]\n"}},
+	}
+
+	for _, test := range tests {
+		extension, err := DefaultRegistry().Extension("export", test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exporter := extension.(*FeedExporter)
+		exporter.ItemScraped(a, exportItem(a, "A"))
+		exporter.ItemScraped(b, exportItem(b, "B"))
+		if err := exporter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		exporter.ItemScraped(a, exportItem(a, "after close"))
+
+		for path, expected := range test.files {
+			content, err := ioutil.ReadFile(filepath.Join(dir, path))
+			if err != nil {
+				t.Errorf("Export to %s failed: %s", path, err)
+				continue
+			}
+			if string(content) != expected {
+				t.Errorf("Export to %s wrote %q, expected %q", path, content, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package gotana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+const (
+	EXTRACT_TEXT = "text"
+	EXTRACT_ATTR = "attr"
+	EXTRACT_HTML = "html"
+)
+
+type ItemFieldConfig struct {
+	Name       string `required:"true"`
+	Selector   string
+	XPath      string
+	JsonPath   string
+	Extract    string
+	Attr       string
+	List       bool
+	Trim       bool
+	Regex      string
+	Processors []string
+}
+
+type ItemConfig struct {
+	Name              string `required:"true"`
+	Container         string
+	ContainerXPath    string
+	ContainerJsonPath string
+	Patterns          []URLPattern
+	Fields            []ItemFieldConfig
+}
+
+type GenericItem struct {
+	ScraperMixin
+	Kind   string
+	Fields map[string]interface{}
+}
+
+func (item GenericItem) Validate() bool {
+	return true
+}
+
+func (item GenericItem) RecordData() ([]byte, error) {
+	return json.Marshal(item.Fields)
+}
+
+func (item GenericItem) String() string {
+	return fmt.Sprintf("<%s: %v>", item.Kind, item.Fields)
+}
+
+func (item GenericItem) Empty() bool {
+	for _, value := range item.Fields {
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				return false
+			}
+		case []string:
+			if len(v) > 0 {
+				return false
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return false
+			}
+		default:
+			if v != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func NewGenericItem(kind string, proxy ScrapedItem) (item GenericItem) {
+	item = GenericItem{
+		Kind:   kind,
+		Fields: make(map[string]interface{}),
+	}
+	item.Proxy = proxy
+	return
+}
+
+type ItemField struct {
+	Name       string
+	Selector   string
+	XPath      string
+	JsonPath   string
+	Extract    string
+	Attr       string
+	List       bool
+	Trim       bool
+	regex      *regexp.Regexp
+	processors []Processor
+}
+
+func (field *ItemField) process(value string, ok bool) (string, bool) {
+	if !ok {
+		return value, ok
+	}
+
+	if field.Trim {
+		value = strings.TrimSpace(value)
+	}
+
+	if field.regex != nil {
+		match := field.regex.FindStringSubmatch(value)
+		if match == nil {
+			return "", false
+		}
+		value = match[len(match)-1]
+	}
+	return value, true
+}
+
+func (field *ItemField) extractSelection(selection *goquery.Selection) (value string, ok bool) {
+	switch field.Extract {
+	case EXTRACT_ATTR:
+		value, ok = selection.Attr(field.Attr)
+	case EXTRACT_HTML:
+		markup, err := selection.Html()
+		value, ok = markup, err == nil
+	default:
+		value, ok = selection.Text(), true
+	}
+	return field.process(value, ok)
+}
+
+func (field *ItemField) extractNode(node XPathNode) (value string, ok bool) {
+	switch field.Extract {
+	case EXTRACT_ATTR:
+		value, ok = node.Attr(field.Attr)
+	case EXTRACT_HTML:
+		value, ok = node.HTML(), true
+	default:
+		value, ok = node.Text(), true
+	}
+	return field.process(value, ok)
+}
+
+func (field *ItemField) xpathValues(selection *goquery.Selection) (values []string) {
+	if len(selection.Nodes) == 0 {
+		return
+	}
+
+	nodes, err := NewHTMLXPathNode(selection.Nodes[0]).Find(field.XPath)
+	if err != nil {
+		Logger().Warningf("Cannot evaluate xpath of field %s: %s", field.Name, err)
+		return
+	}
+
+	for _, node := range nodes {
+		if value, ok := field.extractNode(node); ok {
+			values = append(values, value)
+			if !field.List {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (field *ItemField) Value(selection *goquery.Selection) interface{} {
+	if field.XPath != "" {
+		values := field.xpathValues(selection)
+		if field.List {
+			if values == nil {
+				values = []string{}
+			}
+			return values
+		}
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+
+	if field.Selector != "" {
+		selection = selection.Find(field.Selector)
+	}
+
+	if !field.List {
+		value, _ := field.extractSelection(selection.First())
+		return value
+	}
+
+	values := []string{}
+	selection.Each(func(i int, s *goquery.Selection) {
+		if value, ok := field.extractSelection(s); ok {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+func (field *ItemField) JSONValue(data interface{}) (interface{}, error) {
+	path, err := CompileJSONPath(field.JsonPath)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Field %s has invalid jsonpath: %s", field.Name, err))
+	}
+
+	values := path.Evaluate(data)
+	if field.List {
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values[0], nil
+}
+
+func (field *ItemField) Process(value interface{}, proxy ScrapedItem) (interface{}, error) {
+	if len(field.processors) == 0 {
+		return value, nil
+	}
+
+	var values []interface{}
+	switch v := value.(type) {
+	case []string:
+		for _, s := range v {
+			values = append(values, s)
+		}
+	case []interface{}:
+		values = v
+	case nil:
+	default:
+		values = []interface{}{v}
+	}
+
+	context := &LoaderContext{Proxy: proxy, Field: field.Name}
+	values, err := RunProcessors(values, context, field.processors...)
+	if err != nil {
+		return value, err
+	}
+
+	if field.List {
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	return values[0], nil
+}
+
+func NewItemField(config ItemFieldConfig) (field ItemField, err error) {
+	field = ItemField{
+		Name:     config.Name,
+		Selector: config.Selector,
+		XPath:    config.XPath,
+		JsonPath: config.JsonPath,
+		Extract:  config.Extract,
+		Attr:     config.Attr,
+		List:     config.List,
+		Trim:     config.Trim,
+	}
+
+	if field.Extract == "" {
+		field.Extract = EXTRACT_TEXT
+	}
+	switch field.Extract {
+	case EXTRACT_TEXT, EXTRACT_HTML:
+	case EXTRACT_ATTR:
+		if field.Attr == "" {
+			err = errors.New(fmt.Sprintf("Field %s extracts attribute but defines none", field.Name))
+			return
+		}
+	default:
+		err = errors.New(fmt.Sprintf("Field %s has unknown extract type: %s", field.Name, field.Extract))
+		return
+	}
+
+	if field.XPath != "" {
+		if _, err = CompileXPath(field.XPath); err != nil {
+			err = errors.New(fmt.Sprintf("Field %s has invalid xpath: %s", field.Name, err))
+			return
+		}
+	}
+
+	if field.JsonPath != "" {
+		if _, err = CompileJSONPath(field.JsonPath); err != nil {
+			err = errors.New(fmt.Sprintf("Field %s has invalid jsonpath: %s", field.Name, err))
+			return
+		}
+	}
+
+	if config.Regex != "" {
+		field.regex, err = regexp.Compile(config.Regex)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Field %s has invalid regex: %s", field.Name, err))
+			return
+		}
+	}
+
+	field.processors, err = ParseProcessors(config.Processors)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Field %s has invalid processor: %s", field.Name, err))
+	}
+	return
+}
+
+type ItemDefinition struct {
+	Name              string
+	Container         string
+	ContainerXPath    string
+	ContainerJsonPath string
+	Fields            []ItemField
+	patterns          *URLPatternSet
+}
+
+func (definition *ItemDefinition) AddFields(fields ...ItemField) *ItemDefinition {
+	definition.Fields = append(definition.Fields, fields...)
+	return definition
+}
+
+func (definition *ItemDefinition) AddPatterns(patterns ...URLPattern) *ItemDefinition {
+	definition.patterns.Add(patterns...)
+	return definition
+}
+
+func (definition *ItemDefinition) Matches(url string) bool {
+	return definition.patterns.Validate(url)
+}
+
+func (definition *ItemDefinition) IsJSON() bool {
+	if definition.ContainerJsonPath != "" {
+		return true
+	}
+	for _, field := range definition.Fields {
+		if field.JsonPath != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (definition *ItemDefinition) setField(item GenericItem, field *ItemField, value interface{}) {
+	value, err := field.Process(value, item.Proxy)
+	if err != nil {
+		Logger().Warningf("Cannot process field %s of %s: %s", field.Name, definition.Name, err)
+	}
+	item.Fields[field.Name] = value
+}
+
+func (definition *ItemDefinition) populate(item GenericItem, selection *goquery.Selection) {
+	for index := range definition.Fields {
+		field := &definition.Fields[index]
+		definition.setField(item, field, field.Value(selection))
+	}
+}
+
+func (definition *ItemDefinition) ExtractJSON(proxy ScrapedItem) (items []SaveableItem, err error) {
+	document, err := proxy.JSON()
+	if err != nil {
+		return
+	}
+
+	containers := []interface{}{document.Data}
+	if definition.ContainerJsonPath != "" {
+		if containers, err = document.Query(definition.ContainerJsonPath); err != nil {
+			return
+		}
+	}
+
+	for _, container := range containers {
+		item := NewGenericItem(definition.Name, proxy)
+		for index := range definition.Fields {
+			field := &definition.Fields[index]
+			value, err := field.JSONValue(container)
+			if err != nil {
+				return nil, err
+			}
+			definition.setField(item, field, value)
+		}
+		if !item.Empty() {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+func (definition *ItemDefinition) Extract(proxy ScrapedItem) (items []SaveableItem, err error) {
+	if definition.IsJSON() {
+		return definition.ExtractJSON(proxy)
+	}
+
+	document, err := proxy.HTMLDocument()
+	if err != nil {
+		return
+	}
+
+	selection := document.Selection
+	if definition.Container != "" {
+		selection = document.Find(definition.Container)
+	} else if definition.ContainerXPath != "" {
+		nodes, err := NewHTMLXPathNode(document.Nodes[0]).Find(definition.ContainerXPath)
+		if err != nil {
+			return nil, err
+		}
+		containers := make([]*html.Node, len(nodes))
+		for index, node := range nodes {
+			containers[index] = node.HTMLNode()
+		}
+		selection = document.FindNodes(containers...)
+	}
+
+	selection.Each(func(i int, s *goquery.Selection) {
+		item := NewGenericItem(definition.Name, proxy)
+		definition.populate(item, s)
+		if !item.Empty() {
+			items = append(items, item)
+		}
+	})
+	return
+}
+
+func (definition *ItemDefinition) String() string {
+	return fmt.Sprintf("<ItemDefinition: %s>. Fields: %d", definition.Name, len(definition.Fields))
+}
+
+func NewItemDefinition(name string, container string) (definition *ItemDefinition) {
+	definition = &ItemDefinition{
+		Name:      name,
+		Container: container,
+		patterns:  NewURLPatternSet(),
+	}
+	return
+}
+
+func NewItemDefinitionFromConfig(config ItemConfig) (definition *ItemDefinition, err error) {
+	definition = NewItemDefinition(config.Name, config.Container)
+	definition.ContainerXPath = config.ContainerXPath
+	definition.ContainerJsonPath = config.ContainerJsonPath
+
+	if config.ContainerJsonPath != "" {
+		if _, err = CompileJSONPath(config.ContainerJsonPath); err != nil {
+			return nil, errors.New(fmt.Sprintf("Item %s has invalid container jsonpath: %s", config.Name, err))
+		}
+	}
+
+	if config.ContainerXPath != "" {
+		if _, err = CompileXPath(config.ContainerXPath); err != nil {
+			return nil, errors.New(fmt.Sprintf("Item %s has invalid container xpath: %s", config.Name, err))
+		}
+	}
+
+	patterns := make([]URLPattern, len(config.Patterns))
+	for index, patternData := range config.Patterns {
+		if patterns[index], err = CompileURLPattern(patternData.Type, patternData.Pattern, patternData.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	definition.AddPatterns(patterns...)
+
+	for _, fieldData := range config.Fields {
+		field, err := NewItemField(fieldData)
+		if err != nil {
+			return nil, err
+		}
+		definition.AddFields(field)
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package gotana
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const issuesPage = `<div class="item"><h3><a href="/a"> A </a></h3><span class="tag">#x</span><span class="tag">#y</span></div>
+<div class="item"><h3><a href="/b">B</a></h3><span class="tag">none</span></div>
+<div class="item"></div>`
+
+func TestItemDefinitionFromYAML(t *testing.T) {
+	data := `
+name: issue
+container: .item
+patterns:
+- type: contains
+  pattern: /issues
+fields:
+- name: title
+  selector: h3 a
+  trim: true
+- name: href
+  selector: h3 a
+  extract: attr
+  attr: href
+- name: tags
+  selector: .tag
+  list: true
+  regex: "#(\\w+)"
+`
+	config := ItemConfig{}
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	definition, err := NewItemDefinitionFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !definition.Matches("http://example.com/issues/1") || definition.Matches("http://example.com/about") {
+		t.Errorf("Definition patterns %v match unexpected urls", config.Patterns)
+	}
+
+	items, err := definition.Extract(ScrapedItem{Url: "http://example.com/issues", FinalUrl: "http://example.com/issues", BodyBytes: []byte(issuesPage)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{"title": "A", "href": "/a", "tags": []string{"x", "y"}},
+		{"title": "B", "href": "/b", "tags": []string{}},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Extracted %v, expected %d items", items, len(expected))
+	}
+	for index, item := range items {
+		generic := item.(GenericItem)
+		if generic.Kind != "issue" || !reflect.DeepEqual(generic.Fields, expected[index]) {
+			t.Errorf("Item %d is %v, expected %v", index, generic, expected[index])
+		}
+	}
+}
+
+func TestItemFieldValue(t *testing.T) {
+	const page = `<article><h1> Title </h1><a class="l" href="/1">one</a><a class="l" href="/2">two</a>
+<p class="body"><b>bold</b></p><span class="price">Price: 12 EUR</span></article>`
+
+	tests := []struct {
+		config   ItemFieldConfig
+		expected interface{}
+	}{
+		{ItemFieldConfig{Name: "untrimmed", Selector: "h1"}, " Title "},
+		{ItemFieldConfig{Name: "trimmed", Selector: "h1", Trim: true}, "Title"},
+		{ItemFieldConfig{Name: "first", Selector: "a.l"}, "one"},
+		{ItemFieldConfig{Name: "attr", Selector: "a.l", Extract: "attr", Attr: "href"}, "/1"},
+		{ItemFieldConfig{Name: "list", Selector: "a.l", Extract: "attr", Attr: "href", List: true}, []string{"/1", "/2"}},
+		{ItemFieldConfig{Name: "missing attr", Selector: "a.l", Extract: "attr", Attr: "rel", List: true}, []string{}},
+		{ItemFieldConfig{Name: "html", Selector: "p.body", Extract: "html"}, "<b>bold</b>"},
+		{ItemFieldConfig{Name: "regex", Selector: ".price", Regex: `(\d+) EUR`}, "12"},
+		{ItemFieldConfig{Name: "regex without match", Selector: ".price", Regex: `USD`}, ""},
+		{ItemFieldConfig{Name: "missing", Selector: ".none"}, ""},
+		{ItemFieldConfig{Name: "whole container", Selector: "", Regex: `Price`}, "Price"},
+	}
+
+	selection := testSelection(t, page).Find("article")
+	for _, test := range tests {
+		field, err := NewItemField(test.config)
+		if err != nil {
+			t.Errorf("%s: NewItemField failed: %s", test.config.Name, err)
+			continue
+		}
+		if value := field.Value(selection); !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: Value returned %#v, expected %#v", test.config.Name, value, test.expected)
+		}
+	}
+}
+
+func TestNewItemDefinitionFromConfigErrors(t *testing.T) {
+	tests := []ItemConfig{
+		{Name: "attr", Fields: []ItemFieldConfig{{Name: "x", Extract: "attr"}}},
+		{Name: "extract", Fields: []ItemFieldConfig{{Name: "x", Extract: "json"}}},
+		{Name: "regex", Fields: []ItemFieldConfig{{Name: "x", Regex: "("}}},
+		{Name: "processor", Fields: []ItemFieldConfig{{Name: "x", Processors: []string{"unknown"}}}},
+		{Name: "pattern", Patterns: []URLPattern{{Type: "unknown", Pattern: "x"}}},
+	}
+	for _, config := range tests {
+		if _, err := NewItemDefinitionFromConfig(config); err == nil {
+			t.Errorf("%s: NewItemDefinitionFromConfig succeeded, expected error", config.Name)
+		}
+	}
+}
+
+func TestEngineExtractsManyItems(t *testing.T) {
+	definition, err := NewItemDefinitionFromConfig(ItemConfig{
+		Name:      "entry",
+		Container: "li",
+		Fields:    []ItemFieldConfig{{Name: "title", Selector: "span"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := NewScraper(ScraperParams{Name: "example", Url: "http://example.com"})
+	scraper.AddItemDefinitions(definition)
+	engine, recorder := newRecordingEngine(scraper)
+
+	page := "<ul>" + strings.Repeat("<li><span>x</span></li>", 300) + "</ul>"
+	done := make(chan struct{})
+	go func() {
+		engine.extractItems(scrapedPage(scraper, "http://example.com/", page))
+		engine.events.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Extraction of many items did not finish")
+	}
+	if count := len(recorder.Items()); count != 300 {
+		t.Errorf("Extracted %d items, expected 300", count)
+	}
+}
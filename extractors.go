@@ -0,0 +1,278 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var defaultLinkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"img":    {"src", "srcset"},
+	"form":   {"action"},
+	"meta":   {"content"},
+}
+
+var followedLinkRels = []string{"next", "prev", "previous", "canonical", "alternate"}
+
+var metaRefreshUrl = regexp.MustCompile(`(?i)url\s*=\s*['"]?([^'"]+)`)
+
+type Link struct {
+	Url      string
+	Text     string
+	Tag      string
+	Attr     string
+	Rel      string
+	NoFollow bool
+}
+
+func (link Link) String() string {
+	return fmt.Sprintf("<Link: %s>. Tag: %s, text: %s", link.Url, link.Tag, link.Text)
+}
+
+type LinkFilter func(link Link) bool
+
+func LinkFromTags(tags ...string) LinkFilter {
+	return func(link Link) bool {
+		for _, tag := range tags {
+			if link.Tag == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func LinkTextMatches(expression string) (LinkFilter, error) {
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid link text pattern %s: %s", expression, err))
+	}
+	return func(link Link) bool {
+		return compiled.MatchString(link.Text)
+	}, nil
+}
+
+type LinkExtractorConfig struct {
+	Tags          []string
+	Attrs         map[string][]string
+	Regions       []string
+	HonorNoFollow bool
+	Text          string
+}
+
+type LinkExtractor struct {
+	Extractable
+	Tags          []string
+	Attrs         map[string][]string
+	Regions       []string
+	HonorNoFollow bool
+	textFilter    LinkFilter
+}
+
+func (extractor *LinkExtractor) attrsFor(tag string) []string {
+	if attrs, ok := extractor.Attrs[tag]; ok {
+		return attrs
+	}
+	tags := extractor.Tags
+	if len(tags) == 0 && len(extractor.Attrs) == 0 {
+		tags = []string{"a"}
+	}
+	for _, candidate := range tags {
+		if candidate == tag {
+			return defaultLinkAttrs[tag]
+		}
+	}
+	return nil
+}
+
+func nodeAttr(node *html.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if strings.ToLower(attr.Key) == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func nodeText(node *html.Node) string {
+	switch node.Data {
+	case "img", "area":
+		alt, _ := nodeAttr(node, "alt")
+		return strings.Join(strings.Fields(alt), " ")
+	}
+	return strings.Join(strings.Fields(goquery.NewDocumentFromNode(node).Text()), " ")
+}
+
+func nodeUrls(node *html.Node, attr string, value string) []string {
+	switch {
+	case node.Data == "meta":
+		equiv, _ := nodeAttr(node, "http-equiv")
+		if strings.ToLower(equiv) != "refresh" {
+			return nil
+		}
+		match := metaRefreshUrl.FindStringSubmatch(value)
+		if match == nil {
+			return nil
+		}
+		return []string{strings.TrimSpace(match[1])}
+	case node.Data == "link":
+		rel, _ := nodeAttr(node, "rel")
+		for _, candidate := range strings.Fields(strings.ToLower(rel)) {
+			for _, followed := range followedLinkRels {
+				if candidate == followed {
+					return []string{strings.TrimSpace(value)}
+				}
+			}
+		}
+		return nil
+	case attr == "srcset":
+		var urls []string
+		for _, candidate := range strings.Split(value, ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				urls = append(urls, fields[0])
+			}
+		}
+		return urls
+	}
+	return []string{strings.TrimSpace(value)}
+}
+
+func (extractor *LinkExtractor) nodeLinks(node *html.Node, callback func(Link)) {
+	attrs := extractor.attrsFor(node.Data)
+	if len(attrs) == 0 {
+		return
+	}
+
+	rel, _ := nodeAttr(node, "rel")
+	noFollow := false
+	for _, candidate := range strings.Fields(strings.ToLower(rel)) {
+		noFollow = noFollow || candidate == "nofollow"
+	}
+	if noFollow && extractor.HonorNoFollow {
+		return
+	}
+
+	text := ""
+	for _, attr := range attrs {
+		value, ok := nodeAttr(node, attr)
+		if !ok {
+			continue
+		}
+		for _, url := range nodeUrls(node, attr, value) {
+			url = trimHash(url)
+			if url == "" {
+				continue
+			}
+			if text == "" {
+				text = nodeText(node)
+			}
+			link := Link{Url: url, Text: text, Tag: node.Data, Attr: attr, Rel: rel, NoFollow: noFollow}
+			if extractor.textFilter == nil || extractor.textFilter(link) {
+				callback(link)
+			}
+		}
+	}
+}
+
+func (extractor *LinkExtractor) walk(node *html.Node, visited map[*html.Node]bool, callback func(Link)) {
+	if visited[node] {
+		return
+	}
+	visited[node] = true
+
+	if node.Type == html.ElementNode {
+		extractor.nodeLinks(node, callback)
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		extractor.walk(child, visited, callback)
+	}
+}
+
+func (extractor *LinkExtractor) ExtractLinks(r io.ReadCloser, callback func(Link)) {
+	defer r.Close()
+
+	document, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+
+	roots := document.Nodes
+	if len(extractor.Regions) > 0 {
+		roots = document.Find(strings.Join(extractor.Regions, ", ")).Nodes
+	}
+
+	visited := make(map[*html.Node]bool)
+	for _, root := range roots {
+		extractor.walk(root, visited, callback)
+	}
+}
+
+func (extractor *LinkExtractor) Extract(r io.ReadCloser, callback func(string)) {
+	extractor.ExtractLinks(r, func(link Link) {
+		callback(link.Url)
+	})
+}
+
+func NewLinkExtractor(config LinkExtractorConfig) (extractor *LinkExtractor, err error) {
+	for _, tag := range config.Tags {
+		if _, ok := defaultLinkAttrs[tag]; !ok {
+			return nil, errors.New(fmt.Sprintf("Link extractor does not support tag: %s", tag))
+		}
+	}
+
+	extractor = &LinkExtractor{
+		Tags:          config.Tags,
+		Attrs:         config.Attrs,
+		Regions:       config.Regions,
+		HonorNoFollow: config.HonorNoFollow,
+	}
+	if config.Text != "" {
+		if extractor.textFilter, err = LinkTextMatches(config.Text); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+type JSONExtractor struct {
+	Paths []string
+}
+
+func (extractor *JSONExtractor) Extract(r io.ReadCloser, callback func(string)) {
+	defer r.Close()
+
+	document, err := NewJSONDocument(r)
+	if err != nil {
+		return
+	}
+
+	for _, path := range extractor.Paths {
+		urls, err := document.Strings(path)
+		if err != nil {
+			Logger().Warningf("Cannot evaluate %s: %s", path, err)
+			continue
+		}
+		for _, url := range urls {
+			callback(trimHash(url))
+		}
+	}
+}
+
+func NewJSONExtractor(paths ...string) (extractor *JSONExtractor, err error) {
+	for _, path := range paths {
+		if _, err = CompileJSONPath(path); err != nil {
+			return
+		}
+	}
+	extractor = &JSONExtractor{Paths: paths}
+	return
+}
@@ -0,0 +1,132 @@
+package gotana
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const linkPage = `<html><head><link rel="stylesheet" href="/s.css"><link rel="next" href="/page/2">
+<link rel="canonical" href="/canon"><meta http-equiv="refresh" content="5; url='/refreshed'"><meta name="x" content="/meta"></head>
+<body><nav><a href="/nav">Nav  link</a></nav><div class="content"><a href="/post#x">Read
+ more</a><a rel="nofollow" href="/login">Login</a><a href="#top">Top</a>
+<img src="/i.png" srcset="/i1.png 1x, /i2.png 2x" alt="pic"><map><area href="/area" alt="A"></map><iframe src="/frame"></iframe>
+<form action="/search"></form><div data-href="/data">x</div></div></body></html>`
+
+func extractLinks(extractor *LinkExtractor, page string) (links []Link) {
+	extractor.ExtractLinks(ioutil.NopCloser(strings.NewReader(page)), func(link Link) {
+		links = append(links, link)
+	})
+	return
+}
+
+func TestLinkExtractor(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   LinkExtractorConfig
+		expected []string
+	}{
+		{"default", LinkExtractorConfig{}, []string{"a:/nav", "a:/post", "a:/login"}},
+		{"nofollow", LinkExtractorConfig{HonorNoFollow: true}, []string{"a:/nav", "a:/post"}},
+		{"region", LinkExtractorConfig{Regions: []string{".content"}}, []string{"a:/post", "a:/login"}},
+		{"head links", LinkExtractorConfig{Tags: []string{"link", "meta"}}, []string{"link:/page/2", "link:/canon", "meta:/refreshed"}},
+		{"images", LinkExtractorConfig{Tags: []string{"img", "area"}},
+			[]string{"img:/i.png", "img:/i1.png", "img:/i2.png", "area:/area"}},
+		{"custom attrs", LinkExtractorConfig{Attrs: map[string][]string{"div": {"data-href"}, "img": {"srcset"}}},
+			[]string{"img:/i1.png", "img:/i2.png", "div:/data"}},
+		{"all", LinkExtractorConfig{
+			Tags:          []string{"a", "link", "meta", "img", "area", "iframe", "form"},
+			Attrs:         map[string][]string{"div": {"data-href"}},
+			Regions:       []string{".content", "head"},
+			HonorNoFollow: true,
+		}, []string{"link:/page/2", "link:/canon", "meta:/refreshed", "a:/post", "img:/i.png", "img:/i1.png", "img:/i2.png",
+			"area:/area", "iframe:/frame", "form:/search", "div:/data"}},
+		{"text", LinkExtractorConfig{Text: "(?i)more$"}, []string{"a:/post"}},
+	}
+
+	for _, test := range tests {
+		extractor, err := NewLinkExtractor(test.config)
+		if err != nil {
+			t.Errorf("%s: NewLinkExtractor failed: %s", test.name, err)
+			continue
+		}
+		var urls []string
+		for _, link := range extractLinks(extractor, linkPage) {
+			urls = append(urls, link.Tag+":"+link.Url)
+		}
+		if !reflect.DeepEqual(urls, test.expected) {
+			t.Errorf("%s: extracted %v, expected %v", test.name, urls, test.expected)
+		}
+	}
+}
+
+func TestLinkExtractorLinkDetails(t *testing.T) {
+	extractor, err := NewLinkExtractor(LinkExtractorConfig{Tags: []string{"a", "img"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Link{
+		{Url: "/nav", Text: "Nav link", Tag: "a", Attr: "href"},
+		{Url: "/post", Text: "Read more", Tag: "a", Attr: "href"},
+		{Url: "/login", Text: "Login", Tag: "a", Attr: "href", Rel: "nofollow", NoFollow: true},
+		{Url: "/i.png", Text: "pic", Tag: "img", Attr: "src"},
+		{Url: "/i1.png", Text: "pic", Tag: "img", Attr: "srcset"},
+		{Url: "/i2.png", Text: "pic", Tag: "img", Attr: "srcset"},
+	}
+	if links := extractLinks(extractor, linkPage); !reflect.DeepEqual(links, expected) {
+		t.Errorf("Extracted %+v, expected %+v", links, expected)
+	}
+}
+
+func TestLinkFilters(t *testing.T) {
+	more, err := LinkTextMatches("(?i)more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchors := LinkFromTags("a", "area")
+
+	tests := []struct {
+		filter   LinkFilter
+		link     Link
+		expected bool
+	}{
+		{more, Link{Text: "Read MORE"}, true},
+		{more, Link{Text: "Read"}, false},
+		{anchors, Link{Tag: "area"}, true},
+		{anchors, Link{Tag: "img"}, false},
+	}
+	for _, test := range tests {
+		if result := test.filter(test.link); result != test.expected {
+			t.Errorf("Filter of %+v returned %v, expected %v", test.link, result, test.expected)
+		}
+	}
+}
+
+func TestNewLinkExtractorErrors(t *testing.T) {
+	for _, config := range []LinkExtractorConfig{{Tags: []string{"blink"}}, {Text: "(["}} {
+		if _, err := NewLinkExtractor(config); err == nil {
+			t.Errorf("NewLinkExtractor(%+v) succeeded, expected error", config)
+		}
+	}
+	if _, err := LinkTextMatches("(["); err == nil {
+		t.Error("LinkTextMatches accepted invalid pattern")
+	}
+}
+
+func TestJSONExtractor(t *testing.T) {
+	extractor, err := NewJSONExtractor("$.items[*].url", "$.next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	body := `{"items":[{"url":"/a"},{"url":"/b"},{"id":3}],"next":"/page/2"}`
+	extractor.Extract(ioutil.NopCloser(strings.NewReader(body)), func(url string) { urls = append(urls, url) })
+	if expected := []string{"/a", "/b", "/page/2"}; !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Extracted %v, expected %v", urls, expected)
+	}
+
+	if _, err := NewJSONExtractor("$["); err == nil {
+		t.Error("NewJSONExtractor accepted invalid path")
+	}
+}
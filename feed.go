@@ -0,0 +1,237 @@
+package gotana
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const FEED_ITEM_KIND = "FeedEntry"
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+type FeedConfig struct {
+	Items    bool
+	NoFollow bool
+}
+
+type FeedEntry struct {
+	ScraperMixin `json:"-"`
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	Published    time.Time `json:"published"`
+	Author       string    `json:"author"`
+	Summary      string    `json:"summary"`
+}
+
+func (entry FeedEntry) Validate() bool {
+	return entry.Link != ""
+}
+
+func (entry FeedEntry) RecordData() ([]byte, error) {
+	return json.Marshal(entry)
+}
+
+func (entry FeedEntry) String() string {
+	return fmt.Sprintf("<%s: %s>. Link: %s", FEED_ITEM_KIND, entry.Title, entry.Link)
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Links       []string `xml:"link"`
+	Guid        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string   `xml:"description"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Id        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Summary string `xml:"summary"`
+	Content string `xml:"content"`
+}
+
+type feedDocument struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (item rssItem) entry() (entry FeedEntry) {
+	entry = FeedEntry{
+		Title:   strings.TrimSpace(item.Title),
+		Link:    firstNonEmpty(item.Links...),
+		Author:  firstNonEmpty(item.Creator, item.Author),
+		Summary: strings.TrimSpace(item.Description),
+	}
+	if entry.Link == "" && strings.HasPrefix(strings.TrimSpace(item.Guid), "http") {
+		entry.Link = strings.TrimSpace(item.Guid)
+	}
+	entry.Published, _ = ParseFeedDate(firstNonEmpty(item.PubDate, item.Date))
+	return
+}
+
+func (item atomEntry) link() string {
+	for _, link := range item.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+func (item atomEntry) entry() (entry FeedEntry) {
+	entry = FeedEntry{
+		Title:   strings.TrimSpace(item.Title),
+		Link:    item.link(),
+		Summary: firstNonEmpty(item.Summary, item.Content),
+	}
+	if len(item.Authors) > 0 {
+		entry.Author = strings.TrimSpace(item.Authors[0].Name)
+	}
+	entry.Published, _ = ParseFeedDate(firstNonEmpty(item.Published, item.Updated))
+	return
+}
+
+func ParseFeedDate(value string) (result time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if result, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("Cannot parse feed date: %s", value))
+	return
+}
+
+func ParseFeed(data []byte) (entries []FeedEntry, err error) {
+	document := feedDocument{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err = decoder.Decode(&document); err != nil {
+		return
+	}
+
+	switch strings.ToLower(document.XMLName.Local) {
+	case "rss":
+		for _, item := range document.Channel.Items {
+			entries = append(entries, item.entry())
+		}
+	case "rdf":
+		for _, item := range document.Items {
+			entries = append(entries, item.entry())
+		}
+	case "feed":
+		for _, item := range document.Entries {
+			entries = append(entries, item.entry())
+		}
+	default:
+		err = errors.New(fmt.Sprintf("Unknown feed format: %s", document.XMLName.Local))
+	}
+	return
+}
+
+type FeedExtractor struct {
+	Items    bool
+	NoFollow bool
+}
+
+func (extractor *FeedExtractor) Extract(r io.ReadCloser, callback func(string)) {
+	defer r.Close()
+
+	if extractor.NoFollow {
+		return
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	entries, err := ParseFeed(data)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.Link != "" {
+			callback(entry.Link)
+		}
+	}
+}
+
+func (extractor *FeedExtractor) ExtractItems(proxy ScrapedItem) (items []SaveableItem, err error) {
+	if !extractor.Items {
+		return
+	}
+
+	entries, err := ParseFeed(proxy.BodyBytes)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, entry := range entries {
+		if entry.Link != "" {
+			if entry.Link, err = resolveURL(proxy.FinalUrl, entry.Link); err != nil {
+				return
+			}
+		}
+		entry.Proxy = proxy
+		items = append(items, entry)
+	}
+	return
+}
+
+func NewFeedExtractor(config FeedConfig) (extractor *FeedExtractor) {
+	extractor = &FeedExtractor{
+		Items:    config.Items,
+		NoFollow: config.NoFollow,
+	}
+	return
+}
@@ -0,0 +1,157 @@
+package gotana
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+<title>Weekly</title><atom:link href="https://example.com/feed" rel="self"/>
+<item><title> Issue 1 </title><atom:link href="https://example.com/self"/><link>https://example.com/issues/1</link>
+<pubDate>Tue, 10 Jun 2003 04:00:00 GMT</pubDate><dc:creator>Bob</dc:creator><description>&lt;p&gt;hi&lt;/p&gt;</description></item>
+<item><title>Issue 2</title><guid>https://example.com/issues/2</guid><author>ann@example.com</author></item>
+<item><title>Issue 3</title><guid isPermaLink="false">issue-3</guid></item>
+</channel></rss>`
+
+const atomFeed = `<feed xmlns="http://www.w3.org/2005/Atom">
+<entry><title>A</title><link rel="self" href="/self"/><link href="/posts/a"/><published>2021-01-02T10:00:00Z</published>
+<author><name>Ann</name></author><summary>sum</summary></entry>
+<entry><title>B</title><link rel="alternate" href="https://other.com/b"/><updated>2021-01-03</updated><content>body</content></entry>
+</feed>`
+
+const rdfFeed = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"
+xmlns:dc="http://purl.org/dc/elements/1.1/"><item><title>R</title><link>https://example.com/r</link>
+<dc:date>2020-02-03T04:05:06Z</dc:date></item></rdf:RDF>`
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []FeedEntry
+	}{
+		{"rss", rssFeed, []FeedEntry{
+			{Title: "Issue 1", Link: "https://example.com/issues/1", Author: "Bob", Summary: "<p>hi</p>",
+				Published: time.Date(2003, 6, 10, 4, 0, 0, 0, time.UTC)},
+			{Title: "Issue 2", Link: "https://example.com/issues/2", Author: "ann@example.com"},
+			{Title: "Issue 3"},
+		}},
+		{"atom", atomFeed, []FeedEntry{
+			{Title: "A", Link: "/posts/a", Author: "Ann", Summary: "sum", Published: time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC)},
+			{Title: "B", Link: "https://other.com/b", Summary: "body", Published: time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)},
+		}},
+		{"rdf", rdfFeed, []FeedEntry{
+			{Title: "R", Link: "https://example.com/r", Published: time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)},
+		}},
+	}
+
+	for _, test := range tests {
+		entries, err := ParseFeed([]byte(test.body))
+		if err != nil {
+			t.Errorf("%s: ParseFeed failed: %s", test.name, err)
+			continue
+		}
+		if len(entries) != len(test.expected) {
+			t.Errorf("%s: ParseFeed returned %d entries, expected %d", test.name, len(entries), len(test.expected))
+			continue
+		}
+		for index, entry := range entries {
+			expected := test.expected[index]
+			if entry.Title != expected.Title || entry.Link != expected.Link || entry.Author != expected.Author ||
+				entry.Summary != expected.Summary || !entry.Published.Equal(expected.Published) {
+				t.Errorf("%s: entry %d is %+v, expected %+v", test.name, index, entry, expected)
+			}
+		}
+	}
+
+	if _, err := ParseFeed([]byte(`<html><body>x</body></html>`)); err == nil {
+		t.Error("ParseFeed accepted html page")
+	}
+}
+
+func TestFeedExtractor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss.xml":
+			w.Write([]byte(rssFeed))
+		case "/atom.xml":
+			w.Write([]byte(atomFeed))
+		default:
+			w.Write([]byte(`<html><a href="/x">x</a></html>`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		config FeedConfig
+		links  []string
+		items  []string
+	}{
+		{"/rss.xml", FeedConfig{}, []string{"https://example.com/issues/1", "https://example.com/issues/2"}, nil},
+		{"/rss.xml", FeedConfig{Items: true, NoFollow: true}, nil,
+			[]string{"https://example.com/issues/1", "https://example.com/issues/2", ""}},
+		{"/atom.xml", FeedConfig{Items: true}, []string{"/posts/a", "https://other.com/b"},
+			[]string{server.URL + "/posts/a", "https://other.com/b"}},
+		{"/page.html", FeedConfig{Items: true}, nil, nil},
+	}
+
+	for _, test := range tests {
+		extractor := NewFeedExtractor(test.config)
+		response, err := http.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var links []string
+		extractor.Extract(ioutil.NopCloser(strings.NewReader(string(body))), func(url string) { links = append(links, url) })
+		if !reflect.DeepEqual(links, test.links) {
+			t.Errorf("%s with %+v followed %v, expected %v", test.path, test.config, links, test.links)
+		}
+
+		items, err := extractor.ExtractItems(ScrapedItem{Url: server.URL + test.path, FinalUrl: server.URL + test.path, BodyBytes: body})
+		if err != nil {
+			t.Errorf("%s: ExtractItems failed: %s", test.path, err)
+		}
+		var itemLinks []string
+		for _, item := range items {
+			itemLinks = append(itemLinks, item.(FeedEntry).Link)
+		}
+		if !reflect.DeepEqual(itemLinks, test.items) {
+			t.Errorf("%s with %+v extracted %v, expected %v", test.path, test.config, itemLinks, test.items)
+		}
+	}
+}
+
+func TestFeedEntryRecordData(t *testing.T) {
+	entry := FeedEntry{Title: "A", Link: "https://example.com/a", Published: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}
+	entry.Proxy = ScrapedItem{Url: "https://example.com/feed"}
+
+	data, err := entry.RecordData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"title": "A", "link": "https://example.com/a", "published": "2021-01-02T00:00:00Z",
+		"author": "", "summary": ""}
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("RecordData returned %v, expected %v", record, expected)
+	}
+	if !entry.Validate() || (FeedEntry{Title: "A"}).Validate() {
+		t.Error("Validate does not require link")
+	}
+}
@@ -0,0 +1,102 @@
+package gotana
+
+import (
+	"container/heap"
+	"sync"
+)
+
+type frontierEntry struct {
+	request  ScheduledRequest
+	sequence int
+}
+
+type frontierQueue struct {
+	entries         []frontierEntry
+	prioritizeDepth bool
+}
+
+func (queue frontierQueue) Len() int {
+	return len(queue.entries)
+}
+
+func (queue frontierQueue) Less(i, j int) bool {
+	a, b := queue.entries[i], queue.entries[j]
+	if queue.prioritizeDepth && a.request.Depth != b.request.Depth {
+		return a.request.Depth < b.request.Depth
+	}
+	return a.sequence < b.sequence
+}
+
+func (queue frontierQueue) Swap(i, j int) {
+	queue.entries[i], queue.entries[j] = queue.entries[j], queue.entries[i]
+}
+
+func (queue *frontierQueue) Push(x interface{}) {
+	queue.entries = append(queue.entries, x.(frontierEntry))
+}
+
+func (queue *frontierQueue) Pop() interface{} {
+	last := len(queue.entries) - 1
+	entry := queue.entries[last]
+	queue.entries = queue.entries[:last]
+	return entry
+}
+
+type Frontier struct {
+	mutex    *sync.Mutex
+	queue    *frontierQueue
+	queued   map[string]bool
+	sequence int
+}
+
+func (frontier *Frontier) Push(request ScheduledRequest) bool {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	if frontier.queued[request.Url] {
+		if request.Page > 0 {
+			frontier.paginate(request)
+		}
+		return false
+	}
+	frontier.queued[request.Url] = true
+	frontier.sequence += 1
+	heap.Push(frontier.queue, frontierEntry{request: request, sequence: frontier.sequence})
+	return true
+}
+
+func (frontier *Frontier) paginate(request ScheduledRequest) {
+	for index := range frontier.queue.entries {
+		entry := &frontier.queue.entries[index]
+		if entry.request.Url == request.Url && entry.request.Page == 0 {
+			entry.request.Page = request.Page
+		}
+	}
+}
+
+func (frontier *Frontier) Pop() (request ScheduledRequest, ok bool) {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+
+	if frontier.queue.Len() == 0 {
+		return
+	}
+	entry := heap.Pop(frontier.queue).(frontierEntry)
+	delete(frontier.queued, entry.request.Url)
+	return entry.request, true
+}
+
+func (frontier *Frontier) Len() int {
+	frontier.mutex.Lock()
+	defer frontier.mutex.Unlock()
+	return frontier.queue.Len()
+}
+
+func NewFrontier(prioritizeDepth bool) (frontier *Frontier) {
+	frontier = &Frontier{
+		mutex:  &sync.Mutex{},
+		queue:  &frontierQueue{prioritizeDepth: prioritizeDepth},
+		queued: make(map[string]bool),
+	}
+	return
+}
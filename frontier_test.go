@@ -0,0 +1,66 @@
+package gotana
+
+import (
+	"reflect"
+	"testing"
+)
+
+func popFrontier(frontier *Frontier) (urls []string) {
+	for request, ok := frontier.Pop(); ok; request, ok = frontier.Pop() {
+		urls = append(urls, request.Url)
+	}
+	return
+}
+
+func TestFrontierOrder(t *testing.T) {
+	requests := []ScheduledRequest{
+		{Url: "http://example.com/a", Depth: 2},
+		{Url: "http://example.com/b", Depth: 1},
+		{Url: "http://example.com/c", Depth: 2},
+		{Url: "http://example.com/d", Depth: 0},
+		{Url: "http://example.com/b", Depth: 0},
+	}
+
+	tests := []struct {
+		prioritizeDepth bool
+		expected        []string
+	}{
+		{false, []string{"http://example.com/a", "http://example.com/b", "http://example.com/c", "http://example.com/d"}},
+		{true, []string{"http://example.com/d", "http://example.com/b", "http://example.com/a", "http://example.com/c"}},
+	}
+
+	for _, test := range tests {
+		frontier := NewFrontier(test.prioritizeDepth)
+		for _, request := range requests {
+			frontier.Push(request)
+		}
+		if urls := popFrontier(frontier); !reflect.DeepEqual(urls, test.expected) {
+			t.Errorf("Frontier with depth priority %v returned %v, expected %v", test.prioritizeDepth, urls, test.expected)
+		}
+	}
+}
+
+func TestFrontierMergesPagination(t *testing.T) {
+	frontier := NewFrontier(false)
+	frontier.Push(ScheduledRequest{Url: "http://example.com/list?page=3", Depth: 2})
+	frontier.Push(ScheduledRequest{Url: "http://example.com/item", Depth: 2})
+
+	if frontier.Push(ScheduledRequest{Url: "http://example.com/list?page=3", Depth: 0, Page: 3}) {
+		t.Error("Frontier queued page twice")
+	}
+	frontier.Push(ScheduledRequest{Url: "http://example.com/item", Depth: 1})
+
+	expected := []ScheduledRequest{
+		{Url: "http://example.com/list?page=3", Depth: 2, Page: 3},
+		{Url: "http://example.com/item", Depth: 2},
+	}
+	for _, request := range expected {
+		if popped, ok := frontier.Pop(); !ok || popped != request {
+			t.Errorf("Frontier returned %+v, expected %+v", popped, request)
+		}
+	}
+
+	if !frontier.Push(ScheduledRequest{Url: "http://example.com/list?page=3"}) {
+		t.Error("Frontier does not queue popped url again")
+	}
+}
@@ -0,0 +1,440 @@
+package gotana
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HISTORY_STORAGE_FILE = "file"
+	HISTORY_STORAGE_DAO  = "dao"
+	HISTORY_DEFAULT_RUNS = 7
+	METRIC_ITEMS         = "items"
+	METRIC_PAGES         = "pages"
+	METRIC_FAILURES      = "failures"
+	METRIC_DURATION      = "duration"
+	METRIC_COVERAGE      = "coverage"
+)
+
+type RunSummary struct {
+	Scraper  string             `json:"scraper"`
+	Started  time.Time          `json:"started"`
+	Finished time.Time          `json:"finished"`
+	Duration float64            `json:"duration"`
+	Pages    int                `json:"pages"`
+	Items    int                `json:"items"`
+	Failures int                `json:"failures"`
+	Coverage map[string]float64 `json:"coverage"`
+}
+
+func (summary RunSummary) Metric(name string) float64 {
+	switch name {
+	case METRIC_ITEMS:
+		return float64(summary.Items)
+	case METRIC_PAGES:
+		return float64(summary.Pages)
+	case METRIC_FAILURES:
+		return float64(summary.Failures)
+	case METRIC_DURATION:
+		return summary.Duration
+	}
+	if strings.HasPrefix(name, METRIC_COVERAGE+".") {
+		return summary.Coverage[strings.TrimPrefix(name, METRIC_COVERAGE+".")]
+	}
+	return 0
+}
+
+type RunHistory interface {
+	Save(summary RunSummary) error
+	Recent(scraper string, limit int) ([]RunSummary, error)
+}
+
+func latestRuns(summaries []RunSummary, limit int) []RunSummary {
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Started.After(summaries[j].Started)
+	})
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries
+}
+
+type FileRunHistory struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func (history *FileRunHistory) Save(summary RunSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	file, err := os.OpenFile(history.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (history *FileRunHistory) Recent(scraper string, limit int) (summaries []RunSummary, err error) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	file, err := os.Open(history.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		summary := RunSummary{}
+		if json.Unmarshal(scanner.Bytes(), &summary) == nil && summary.Scraper == scraper {
+			summaries = append(summaries, summary)
+		}
+	}
+	return latestRuns(summaries, limit), scanner.Err()
+}
+
+func NewFileRunHistory(path string) *FileRunHistory {
+	return &FileRunHistory{Path: path}
+}
+
+type DAORunHistory struct {
+	store RunStore
+}
+
+func (history *DAORunHistory) Save(summary RunSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	return history.store.SaveRun(summary.Scraper, data)
+}
+
+func (history *DAORunHistory) Recent(scraper string, limit int) (summaries []RunSummary, err error) {
+	records, err := history.store.LatestRuns(scraper, limit)
+	if err != nil {
+		return
+	}
+	for _, record := range records {
+		summary := RunSummary{}
+		if json.Unmarshal([]byte(record), &summary) == nil {
+			summaries = append(summaries, summary)
+		}
+	}
+	return latestRuns(summaries, limit), nil
+}
+
+func NewDAORunHistory(store RunStore) *DAORunHistory {
+	return &DAORunHistory{store: store}
+}
+
+type Anomaly struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Actual    float64 `json:"actual"`
+	Deviation float64 `json:"deviation"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+func (anomaly Anomaly) String() string {
+	return fmt.Sprintf("%s: %.2f against baseline %.2f (deviation %.2f, tolerance %.2f)",
+		anomaly.Metric, anomaly.Actual, anomaly.Baseline, anomaly.Deviation, anomaly.Tolerance)
+}
+
+type AnomalyNotifier interface {
+	NotifyAnomalies(scraper *Scraper, summary RunSummary, anomalies []Anomaly)
+}
+
+func Baseline(runs []RunSummary) (baseline RunSummary) {
+	if len(runs) == 0 {
+		return
+	}
+
+	baseline.Scraper = runs[0].Scraper
+	baseline.Coverage = make(map[string]float64)
+	count := float64(len(runs))
+	for _, run := range runs {
+		baseline.Duration += run.Duration / count
+		baseline.Pages += run.Pages
+		baseline.Items += run.Items
+		baseline.Failures += run.Failures
+		for field, coverage := range run.Coverage {
+			baseline.Coverage[field] += coverage / count
+		}
+	}
+	baseline.Pages = int(math.Round(float64(baseline.Pages) / count))
+	baseline.Items = int(math.Round(float64(baseline.Items) / count))
+	baseline.Failures = int(math.Round(float64(baseline.Failures) / count))
+	return
+}
+
+func CompareRuns(summary RunSummary, baseline RunSummary, tolerances map[string]float64) (anomalies []Anomaly) {
+	metrics := make([]string, 0, len(tolerances))
+	for metric := range tolerances {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	for _, metric := range metrics {
+		tolerance := tolerances[metric]
+		if metric == METRIC_COVERAGE {
+			fields := make([]string, 0, len(baseline.Coverage))
+			for field := range baseline.Coverage {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				name := METRIC_COVERAGE + "." + field
+				drop := baseline.Metric(name) - summary.Metric(name)
+				if drop > tolerance {
+					anomalies = append(anomalies, Anomaly{Metric: name, Baseline: baseline.Metric(name),
+						Actual: summary.Metric(name), Deviation: drop, Tolerance: tolerance})
+				}
+			}
+			continue
+		}
+
+		expected, actual := baseline.Metric(metric), summary.Metric(metric)
+		deviation := math.Abs(actual-expected) / math.Max(expected, 1)
+		if deviation > tolerance {
+			anomalies = append(anomalies, Anomaly{Metric: metric, Baseline: expected, Actual: actual,
+				Deviation: deviation, Tolerance: tolerance})
+		}
+	}
+	return
+}
+
+type HistoryConfig struct {
+	Storage    string
+	Path       string
+	Runs       int
+	MinRuns    int
+	Tolerances map[string]float64
+}
+
+type RunComparator struct {
+	Config  HistoryConfig
+	history RunHistory
+	mutex   sync.Mutex
+	runs    map[string]*qualityRun
+}
+
+func (comparator *RunComparator) run(scraper *Scraper) *qualityRun {
+	run, ok := comparator.runs[scraper.Name]
+	if !ok {
+		run = newQualityRun()
+		comparator.runs[scraper.Name] = run
+	}
+	return run
+}
+
+func (comparator *RunComparator) ScraperStarted(scraper *Scraper) {
+	comparator.mutex.Lock()
+	defer comparator.mutex.Unlock()
+	comparator.run(scraper)
+}
+
+func (comparator *RunComparator) ItemScraped(scraper *Scraper, item SaveableItem) {
+	if scraper == nil {
+		return
+	}
+	record := itemRecord(item)
+
+	comparator.mutex.Lock()
+	defer comparator.mutex.Unlock()
+	if run, ok := comparator.runs[scraper.Name]; ok {
+		run.add(record)
+	}
+}
+
+func (comparator *RunComparator) storage(scraper *Scraper) RunHistory {
+	if comparator.history != nil {
+		return comparator.history
+	}
+	if store, ok := GetDAO(scraper.engine).(RunStore); ok {
+		return NewDAORunHistory(store)
+	}
+	return nil
+}
+
+func (comparator *RunComparator) Summarize(scraper *Scraper) (summary RunSummary) {
+	comparator.mutex.Lock()
+	run, ok := comparator.runs[scraper.Name]
+	if !ok {
+		run = newQualityRun()
+	}
+	delete(comparator.runs, scraper.Name)
+	comparator.mutex.Unlock()
+
+	summary = RunSummary{
+		Scraper:  scraper.Name,
+		Started:  run.started,
+		Finished: time.Now(),
+		Items:    run.items,
+		Coverage: run.coverage(),
+	}
+	summary.Duration = summary.Finished.Sub(summary.Started).Seconds()
+	if scraper.engine != nil {
+		crawled, _, failed := scraper.engine.Meta.RequestStats(scraper)
+		summary.Pages, summary.Failures = crawled, failed
+	}
+	return
+}
+
+func (comparator *RunComparator) ScraperStopped(scraper *Scraper) {
+	summary := comparator.Summarize(scraper)
+
+	history := comparator.storage(scraper)
+	if history == nil {
+		Logger().Warningf("No storage of run history for %s", scraper.Name)
+		return
+	}
+
+	previous, err := history.Recent(scraper.Name, comparator.Config.Runs)
+	if err != nil {
+		Logger().Warningf("Cannot read run history of %s: %s", scraper.Name, err)
+	}
+	if err = history.Save(summary); err != nil {
+		Logger().Warningf("Cannot save run summary of %s: %s", scraper.Name, err)
+	}
+
+	if len(previous) < comparator.Config.MinRuns {
+		return
+	}
+
+	anomalies := CompareRuns(summary, Baseline(previous), comparator.Config.Tolerances)
+	if len(anomalies) == 0 {
+		return
+	}
+
+	for _, anomaly := range anomalies {
+		Logger().Warningf("Anomaly in %s. %s", scraper.Name, anomaly)
+	}
+	if scraper.engine != nil {
+		for _, extension := range scraper.engine.extensions {
+			if notifier, ok := extension.(AnomalyNotifier); ok {
+				notifier.NotifyAnomalies(scraper, summary, anomalies)
+			}
+		}
+	}
+}
+
+func NewRunComparator(config HistoryConfig) (comparator *RunComparator, err error) {
+	if config.Runs == 0 {
+		config.Runs = HISTORY_DEFAULT_RUNS
+	}
+	if config.MinRuns == 0 {
+		config.MinRuns = 1
+	}
+
+	comparator = &RunComparator{
+		Config: config,
+		runs:   make(map[string]*qualityRun),
+	}
+
+	switch config.Storage {
+	case HISTORY_STORAGE_FILE:
+		if config.Path == "" {
+			return nil, errors.New("File run history requires path")
+		}
+		comparator.history = NewFileRunHistory(config.Path)
+	case "", HISTORY_STORAGE_DAO:
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown run history storage: %s", config.Storage))
+	}
+	return
+}
+
+func (comparator *RunComparator) SetHistory(history RunHistory) *RunComparator {
+	comparator.history = history
+	return comparator
+}
+
+type WebhookNotifier struct {
+	Url string
+}
+
+func (notifier *WebhookNotifier) ScraperStarted(scraper *Scraper) {
+
+}
+
+func (notifier *WebhookNotifier) ScraperStopped(scraper *Scraper) {
+
+}
+
+func (notifier *WebhookNotifier) ItemScraped(scraper *Scraper, item SaveableItem) {
+
+}
+
+func (notifier *WebhookNotifier) post(payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	response, err := NewHTTPClient().Post(notifier.Url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		Logger().Warningf("Cannot notify %s: %s", notifier.Url, err)
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		Logger().Warningf("Notification to %s failed with status %d", notifier.Url, response.StatusCode)
+	}
+}
+
+func (notifier *WebhookNotifier) NotifyAnomalies(scraper *Scraper, summary RunSummary, anomalies []Anomaly) {
+	notifier.post(map[string]interface{}{
+		"scraper":   scraper.Name,
+		"summary":   summary,
+		"anomalies": anomalies,
+	})
+}
+
+func (notifier *WebhookNotifier) QualityReport(scraper *Scraper, report QualityReport) {
+	if !report.Passed {
+		notifier.post(map[string]interface{}{
+			"scraper": scraper.Name,
+			"report":  report,
+		})
+	}
+}
+
+func init() {
+	RegisterExtension("history", func(options ComponentOptions) (Extension, error) {
+		config := HistoryConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewRunComparator(config)
+	})
+	RegisterExtension("webhook", func(options ComponentOptions) (Extension, error) {
+		config := struct{ Url string }{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		if config.Url == "" {
+			return nil, errors.New("Webhook notifier requires url")
+		}
+		return &WebhookNotifier{Url: config.Url}, nil
+	})
+}
@@ -0,0 +1,190 @@
+package gotana
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func runSummaries(scraper string, items ...int) (summaries []RunSummary) {
+	started := time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC)
+	for index, count := range items {
+		summaries = append(summaries, RunSummary{
+			Scraper:  scraper,
+			Started:  started.Add(time.Duration(index) * time.Hour),
+			Items:    count,
+			Pages:    count / 2,
+			Coverage: map[string]float64{"title": 1},
+		})
+	}
+	return
+}
+
+func checkRunHistory(t *testing.T, name string, history RunHistory) {
+	for _, summary := range append(runSummaries("books", 10, 20, 30), runSummaries("other", 1)...) {
+		if err := history.Save(summary); err != nil {
+			t.Fatalf("%s: Save failed: %s", name, err)
+		}
+	}
+
+	runs, err := history.Recent("books", 2)
+	if err != nil {
+		t.Fatalf("%s: Recent failed: %s", name, err)
+	}
+	if len(runs) != 2 || runs[0].Items != 30 || runs[1].Items != 20 {
+		t.Errorf("%s: Recent returned %+v, expected two latest runs", name, runs)
+	}
+	if runs, _ = history.Recent("books", 0); len(runs) != 3 {
+		t.Errorf("%s: Recent without limit returned %d runs, expected 3", name, len(runs))
+	}
+	if runs, _ = history.Recent("unknown", 2); len(runs) != 0 {
+		t.Errorf("%s: Recent of unknown scraper returned %+v", name, runs)
+	}
+}
+
+func TestFileRunHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotana-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	checkRunHistory(t, "file", NewFileRunHistory(filepath.Join(dir, "runs.jsonl")))
+}
+
+func TestDAORunHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotana-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	bolt, err := NewBoltDAO(filepath.Join(dir, "items.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+
+	daos := map[string]DAO{
+		"memory": NewMemoryDAO().WithTTL(time.Millisecond),
+		"bolt":   bolt.WithTTL(time.Millisecond),
+		"redis":  NewRedisDao(server.Addr()).WithTTL(time.Millisecond),
+	}
+	for name, dao := range daos {
+		checkRunHistory(t, name, NewDAORunHistory(dao.(RunStore)))
+
+		time.Sleep(5 * time.Millisecond)
+		if err := dao.SaveItem("books", []byte(`{"title":"item"}`)); err != nil {
+			t.Fatalf("%s: SaveItem failed: %s", name, err)
+		}
+		if _, err := dao.PurgeOlderThan("books", 0); err != nil {
+			t.Fatalf("%s: PurgeOlderThan failed: %s", name, err)
+		}
+		for _, scraper := range []string{"books", "runs-books", RUNS_NAMESPACE + ":books"} {
+			if count := dao.CountItems(scraper); count != 0 {
+				t.Errorf("%s: CountItems(%q) returned %d, expected no items", name, scraper, count)
+			}
+		}
+		if runs, _ := NewDAORunHistory(dao.(RunStore)).Recent("books", 0); len(runs) != 3 {
+			t.Errorf("%s: Runs were removed with expired items, %d left", name, len(runs))
+		}
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	baseline := Baseline(runSummaries("books", 90, 100, 110))
+	if baseline.Items != 100 || baseline.Pages != 50 || baseline.Coverage["title"] != 1 {
+		t.Fatalf("Baseline returned %+v", baseline)
+	}
+
+	tolerances := map[string]float64{METRIC_ITEMS: 0.3, METRIC_PAGES: 0.3, METRIC_COVERAGE: 0.1}
+	tests := []struct {
+		summary  RunSummary
+		expected []string
+	}{
+		{RunSummary{Items: 100, Pages: 50, Coverage: map[string]float64{"title": 1}}, nil},
+		{RunSummary{Items: 75, Pages: 40, Coverage: map[string]float64{"title": 0.95}}, nil},
+		{RunSummary{Items: 40, Pages: 50, Coverage: map[string]float64{"title": 1}}, []string{"items"}},
+		{RunSummary{Items: 140, Pages: 70, Coverage: map[string]float64{"title": 1}}, []string{"items", "pages"}},
+		{RunSummary{Items: 100, Pages: 50, Coverage: map[string]float64{"title": 0.5}}, []string{"coverage.title"}},
+		{RunSummary{Items: 100, Pages: 50}, []string{"coverage.title"}},
+	}
+
+	for _, test := range tests {
+		var metrics []string
+		for _, anomaly := range CompareRuns(test.summary, baseline, tolerances) {
+			metrics = append(metrics, anomaly.Metric)
+		}
+		if len(metrics) != len(test.expected) {
+			t.Errorf("CompareRuns(%+v) returned %v, expected %v", test.summary, metrics, test.expected)
+			continue
+		}
+		for index := range metrics {
+			if metrics[index] != test.expected[index] {
+				t.Errorf("CompareRuns(%+v) returned %v, expected %v", test.summary, metrics, test.expected)
+			}
+		}
+	}
+}
+
+func TestRunComparatorNotifiesAnomalies(t *testing.T) {
+	var mutex sync.Mutex
+	notified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		notified += 1
+	}))
+	defer server.Close()
+
+	comparator, err := DefaultRegistry().Extension("history", NewComponentOptions(map[string]interface{}{
+		"minruns":    2,
+		"tolerances": map[string]interface{}{"items": 0.5},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook, err := DefaultRegistry().Extension("webhook", NewComponentOptions(map[string]interface{}{"url": server.URL}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine()
+	engine.dao = NewMemoryDAO()
+	engine.extensions = append(engine.extensions, comparator, webhook)
+	scraper := NewScraper(ScraperParams{Name: "books", Url: "http://example.com"})
+	engine.AddScrapers(scraper)
+
+	for _, count := range []int{10, 10, 10, 2} {
+		comparator.ScraperStarted(scraper)
+		for index := 0; index < count; index++ {
+			comparator.ItemScraped(scraper, GenericItem{Kind: "book", Fields: map[string]interface{}{"title": index}})
+		}
+		comparator.ScraperStopped(scraper)
+	}
+	comparator.ItemScraped(scraper, GenericItem{Kind: "book", Fields: map[string]interface{}{"title": "late"}})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if notified != 1 {
+		t.Errorf("Webhook was notified %d times, expected once", notified)
+	}
+	if count := engine.dao.CountItems("runs-books"); count != 0 {
+		t.Errorf("Run summaries were saved as %d items", count)
+	}
+	if runs, _ := engine.dao.(RunStore).LatestRuns("books", 0); len(runs) != 4 {
+		t.Errorf("Storage keeps %d runs, expected 4", len(runs))
+	}
+}
@@ -0,0 +1,548 @@
+package gotana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	JSONPATH_CHILD = iota
+	JSONPATH_WILDCARD
+	JSONPATH_INDEX
+	JSONPATH_SLICE
+	JSONPATH_FILTER
+)
+
+type jsonPathFilter struct {
+	path     *JSONPath
+	operator string
+	value    interface{}
+}
+
+func (filter *jsonPathFilter) matches(node interface{}) bool {
+	values := filter.path.Evaluate(node)
+	if filter.operator == "" {
+		return len(values) > 0
+	}
+
+	for _, value := range values {
+		if compareJSONValues(value, filter.operator, filter.value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareJSONValues(left interface{}, operator string, right interface{}) bool {
+	leftNumber, leftIsNumber := left.(float64)
+	rightNumber, rightIsNumber := right.(float64)
+
+	if leftIsNumber && rightIsNumber {
+		switch operator {
+		case "==":
+			return leftNumber == rightNumber
+		case "!=":
+			return leftNumber != rightNumber
+		case "<":
+			return leftNumber < rightNumber
+		case "<=":
+			return leftNumber <= rightNumber
+		case ">":
+			return leftNumber > rightNumber
+		case ">=":
+			return leftNumber >= rightNumber
+		}
+		return false
+	}
+
+	switch operator {
+	case "==":
+		return reflect.DeepEqual(left, right)
+	case "!=":
+		return !reflect.DeepEqual(left, right)
+	}
+	return false
+}
+
+type jsonPathStep struct {
+	kind      int
+	recursive bool
+	names     []string
+	indices   []int
+	start     *int
+	end       *int
+	stride    int
+	filter    *jsonPathFilter
+}
+
+func (step *jsonPathStep) apply(node interface{}, result []interface{}) []interface{} {
+	switch step.kind {
+	case JSONPATH_CHILD:
+		if object, ok := node.(map[string]interface{}); ok {
+			for _, name := range step.names {
+				if value, ok := object[name]; ok {
+					result = append(result, value)
+				}
+			}
+		}
+	case JSONPATH_WILDCARD:
+		result = append(result, jsonMembers(node)...)
+	case JSONPATH_INDEX:
+		if array, ok := node.([]interface{}); ok {
+			for _, index := range step.indices {
+				if index < 0 {
+					index += len(array)
+				}
+				if index >= 0 && index < len(array) {
+					result = append(result, array[index])
+				}
+			}
+		}
+	case JSONPATH_SLICE:
+		if array, ok := node.([]interface{}); ok {
+			result = step.slice(array, result)
+		}
+	case JSONPATH_FILTER:
+		for _, member := range jsonMembers(node) {
+			if step.filter.matches(member) {
+				result = append(result, member)
+			}
+		}
+	}
+	return result
+}
+
+func (step *jsonPathStep) slice(array []interface{}, result []interface{}) []interface{} {
+	length := len(array)
+	if step.stride > 0 {
+		start, end := 0, length
+		if step.start != nil {
+			start = normalizeSliceIndex(*step.start, length, 0)
+		}
+		if step.end != nil {
+			end = normalizeSliceIndex(*step.end, length, 0)
+		}
+		for index := start; index < end; index += step.stride {
+			result = append(result, array[index])
+		}
+		return result
+	}
+
+	start, end := length-1, -1
+	if step.start != nil {
+		start = normalizeSliceIndex(*step.start, length, -1)
+	}
+	if step.end != nil {
+		end = normalizeSliceIndex(*step.end, length, -1)
+	}
+	for index := start; index > end; index += step.stride {
+		if index < length {
+			result = append(result, array[index])
+		}
+	}
+	return result
+}
+
+func normalizeSliceIndex(index int, length int, lower int) int {
+	if index < 0 {
+		index += length
+	}
+	if index < lower {
+		return lower
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+func jsonMembers(node interface{}) []interface{} {
+	switch container := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(container))
+		for key := range container {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		members := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			members = append(members, container[key])
+		}
+		return members
+	case []interface{}:
+		return container
+	}
+	return nil
+}
+
+func collectDescendants(node interface{}, result []interface{}) []interface{} {
+	result = append(result, node)
+	switch container := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(container))
+		for key := range container {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = collectDescendants(container[key], result)
+		}
+	case []interface{}:
+		for _, element := range container {
+			result = collectDescendants(element, result)
+		}
+	}
+	return result
+}
+
+type JSONPath struct {
+	Expression string
+	steps      []jsonPathStep
+}
+
+func (path *JSONPath) String() string {
+	return path.Expression
+}
+
+func (path *JSONPath) Evaluate(root interface{}) []interface{} {
+	nodes := []interface{}{root}
+
+	for index := range path.steps {
+		step := &path.steps[index]
+		if step.recursive {
+			var descendants []interface{}
+			for _, node := range nodes {
+				descendants = collectDescendants(node, descendants)
+			}
+			nodes = descendants
+		}
+
+		var next []interface{}
+		for _, node := range nodes {
+			next = step.apply(node, next)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+type jsonPathParser struct {
+	expression string
+	position   int
+}
+
+func (parser *jsonPathParser) errorf(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	return errors.New(fmt.Sprintf("Invalid JSONPath %q at %d: %s", parser.expression, parser.position, message))
+}
+
+func (parser *jsonPathParser) done() bool {
+	return parser.position >= len(parser.expression)
+}
+
+func (parser *jsonPathParser) peek() byte {
+	return parser.expression[parser.position]
+}
+
+func (parser *jsonPathParser) readName() string {
+	start := parser.position
+	for !parser.done() {
+		c := parser.peek()
+		if c == '.' || c == '[' || c == ' ' || c == ')' || c == '=' || c == '!' || c == '<' || c == '>' {
+			break
+		}
+		parser.position++
+	}
+	return parser.expression[start:parser.position]
+}
+
+func (parser *jsonPathParser) readBracket() (content string, err error) {
+	start := parser.position
+	depth := 0
+	var quote byte
+	for !parser.done() {
+		c := parser.peek()
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ']':
+			if depth == 0 {
+				content = parser.expression[start:parser.position]
+				parser.position++
+				return
+			}
+			depth--
+		}
+		parser.position++
+	}
+	err = parser.errorf("unterminated bracket")
+	return
+}
+
+func parseBracketStep(content string) (step jsonPathStep, err error) {
+	content = strings.TrimSpace(content)
+
+	switch {
+	case content == "*":
+		step.kind = JSONPATH_WILDCARD
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		step.kind = JSONPATH_FILTER
+		step.filter, err = parseFilter(content[2 : len(content)-1])
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, "\""):
+		step.kind = JSONPATH_CHILD
+		for _, chunk := range strings.Split(content, ",") {
+			chunk = strings.TrimSpace(chunk)
+			if len(chunk) < 2 {
+				return step, errors.New(fmt.Sprintf("Invalid member name: %s", chunk))
+			}
+			step.names = append(step.names, chunk[1:len(chunk)-1])
+		}
+	case strings.Contains(content, ":"):
+		step.kind = JSONPATH_SLICE
+		bounds := strings.SplitN(content, ":", 3)
+		if step.start, err = parseOptionalInt(bounds[0]); err != nil {
+			return
+		}
+		if step.end, err = parseOptionalInt(bounds[1]); err != nil {
+			return
+		}
+		step.stride = 1
+		if len(bounds) == 3 {
+			var stride *int
+			if stride, err = parseOptionalInt(bounds[2]); err != nil {
+				return
+			}
+			if stride != nil {
+				step.stride = *stride
+			}
+		}
+		if step.stride == 0 {
+			err = errors.New(fmt.Sprintf("Invalid slice step: %s", content))
+		}
+	default:
+		step.kind = JSONPATH_INDEX
+		for _, chunk := range strings.Split(content, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(chunk))
+			if err != nil {
+				return step, errors.New(fmt.Sprintf("Invalid index: %s", chunk))
+			}
+			step.indices = append(step.indices, index)
+		}
+	}
+	return
+}
+
+func parseOptionalInt(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Invalid slice bound: %s", s))
+	}
+	return &value, nil
+}
+
+func parseFilter(expression string) (filter *jsonPathFilter, err error) {
+	expression = strings.TrimSpace(expression)
+	filter = &jsonPathFilter{}
+
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		index := strings.Index(expression, operator)
+		if index == -1 {
+			continue
+		}
+		filter.operator = operator
+		literal := strings.TrimSpace(expression[index+len(operator):])
+		expression = strings.TrimSpace(expression[:index])
+
+		if strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") && len(literal) >= 2 {
+			filter.value = literal[1 : len(literal)-1]
+		} else if err = json.Unmarshal([]byte(literal), &filter.value); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid filter value: %s", literal))
+		}
+		break
+	}
+
+	if !strings.HasPrefix(expression, "@") {
+		return nil, errors.New(fmt.Sprintf("Filter must start with @: %s", expression))
+	}
+	filter.path, err = CompileJSONPath(expression)
+	return
+}
+
+func (parser *jsonPathParser) parse() (steps []jsonPathStep, err error) {
+	if !parser.done() && (parser.peek() == '$' || parser.peek() == '@') {
+		parser.position++
+	}
+
+	for !parser.done() {
+		recursive := false
+		c := parser.peek()
+
+		switch c {
+		case '.':
+			parser.position++
+			if !parser.done() && parser.peek() == '.' {
+				recursive = true
+				parser.position++
+			}
+			if parser.done() {
+				return nil, parser.errorf("unexpected end")
+			}
+			if parser.peek() == '[' {
+				parser.position++
+				content, err := parser.readBracket()
+				if err != nil {
+					return nil, err
+				}
+				step, err := parseBracketStep(content)
+				if err != nil {
+					return nil, parser.errorf("%s", err)
+				}
+				step.recursive = recursive
+				steps = append(steps, step)
+				continue
+			}
+			name := parser.readName()
+			if name == "" {
+				return nil, parser.errorf("empty member name")
+			}
+			step := jsonPathStep{kind: JSONPATH_CHILD, names: []string{name}, recursive: recursive}
+			if name == "*" {
+				step = jsonPathStep{kind: JSONPATH_WILDCARD, recursive: recursive}
+			}
+			steps = append(steps, step)
+		case '[':
+			parser.position++
+			content, err := parser.readBracket()
+			if err != nil {
+				return nil, err
+			}
+			step, err := parseBracketStep(content)
+			if err != nil {
+				return nil, parser.errorf("%s", err)
+			}
+			steps = append(steps, step)
+		default:
+			if len(steps) > 0 || parser.position > 0 {
+				return nil, parser.errorf("unexpected character %q", c)
+			}
+			name := parser.readName()
+			steps = append(steps, jsonPathStep{kind: JSONPATH_CHILD, names: []string{name}})
+		}
+	}
+	return
+}
+
+const JSONPATH_CACHE_SIZE = 1024
+
+var jsonPathCacheMutex = &sync.RWMutex{}
+var jsonPathCache = make(map[string]*JSONPath)
+
+func CompileJSONPath(expression string) (path *JSONPath, err error) {
+	jsonPathCacheMutex.RLock()
+	path, ok := jsonPathCache[expression]
+	jsonPathCacheMutex.RUnlock()
+	if ok {
+		return
+	}
+
+	parser := &jsonPathParser{expression: strings.TrimSpace(expression)}
+	steps, err := parser.parse()
+	if err != nil {
+		return nil, err
+	}
+	path = &JSONPath{Expression: expression, steps: steps}
+
+	jsonPathCacheMutex.Lock()
+	if len(jsonPathCache) >= JSONPATH_CACHE_SIZE {
+		jsonPathCache = make(map[string]*JSONPath)
+	}
+	jsonPathCache[expression] = path
+	jsonPathCacheMutex.Unlock()
+	return
+}
+
+type JSONDocument struct {
+	Data interface{}
+}
+
+func (document *JSONDocument) Query(expression string) ([]interface{}, error) {
+	return QueryJSON(document.Data, expression)
+}
+
+func (document *JSONDocument) QueryOne(expression string) (value interface{}, ok bool, err error) {
+	values, err := document.Query(expression)
+	if err == nil && len(values) > 0 {
+		value, ok = values[0], true
+	}
+	return
+}
+
+func (document *JSONDocument) Strings(expression string) (result []string, err error) {
+	values, err := document.Query(expression)
+	for _, value := range values {
+		if s, ok := JSONValueString(value); ok {
+			result = append(result, s)
+		}
+	}
+	return
+}
+
+func QueryJSON(data interface{}, expression string) ([]interface{}, error) {
+	path, err := CompileJSONPath(expression)
+	if err != nil {
+		return nil, err
+	}
+	return path.Evaluate(data), nil
+}
+
+func JSONValueString(value interface{}) (result string, ok bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return
+}
+
+func NewJSONDocument(r io.Reader) (document *JSONDocument, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	document = &JSONDocument{}
+	if err = json.Unmarshal(data, &document.Data); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (proxy ScrapedItem) JSON() (*JSONDocument, error) {
+	responseBody, err := proxy.FinalResponseBody()
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONDocument(responseBody)
+}
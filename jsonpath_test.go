@@ -0,0 +1,118 @@
+package gotana
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const jsonPathDocument = `{
+	"store": {
+		"numbers": [0, 1, 2, 3, 4, 5],
+		"book": [
+			{"title": "A", "price": 8},
+			{"title": "B", "price": 12, "tags": ["x"]},
+			{"title": "C"}
+		],
+		"bicycle": {"title": "D", "price": 20}
+	},
+	"items": {
+		"first": {"id": 1},
+		"second": {"id": 2}
+	}
+}`
+
+func TestJSONPathEvaluate(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(jsonPathDocument), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expression string
+		expected   []interface{}
+	}{
+		{"$.store.numbers[1]", []interface{}{1.0}},
+		{"$.store.numbers[-1]", []interface{}{5.0}},
+		{"$.store.numbers[0,2]", []interface{}{0.0, 2.0}},
+		{"$.store.numbers[1:3]", []interface{}{1.0, 2.0}},
+		{"$.store.numbers[-2:]", []interface{}{4.0, 5.0}},
+		{"$.store.numbers[::2]", []interface{}{0.0, 2.0, 4.0}},
+		{"$.store.numbers[1::2]", []interface{}{1.0, 3.0, 5.0}},
+		{"$.store.numbers[1:5:3]", []interface{}{1.0, 4.0}},
+		{"$.store.numbers[::-2]", []interface{}{5.0, 3.0, 1.0}},
+		{"$.store.numbers[4:1:-1]", []interface{}{4.0, 3.0, 2.0}},
+		{"$.store.numbers[10:]", nil},
+		{"$.store.book[*].title", []interface{}{"A", "B", "C"}},
+		{"$['store']['bicycle']['title']", []interface{}{"D"}},
+		{"$.store.book[?(@.price > 10)].title", []interface{}{"B"}},
+		{"$.store.book[?(@.title == 'C')].title", []interface{}{"C"}},
+		{"$.store.book[?(@.tags)].title", []interface{}{"B"}},
+		{"$.items[?(@.id==2)]", []interface{}{map[string]interface{}{"id": 2.0}}},
+		{"$.items[?(@.id)].id", []interface{}{1.0, 2.0}},
+		{"$..[?(@.price)].title", []interface{}{"D", "A", "B"}},
+		{"$..[?(@.price > 10)].price", []interface{}{20.0, 12.0}},
+		{"$..id", []interface{}{1.0, 2.0}},
+	}
+
+	for _, test := range tests {
+		path, err := CompileJSONPath(test.expression)
+		if err != nil {
+			t.Errorf("CompileJSONPath(%q) failed: %s", test.expression, err)
+			continue
+		}
+		if result := path.Evaluate(document); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s returned %v, expected %v", test.expression, result, test.expected)
+		}
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	for _, expression := range []string{"$.", "$[", "$[abc]", "$.a[?(x)]", "$.a[::0]", "$.a[1:x]", "$.a[::x]"} {
+		if _, err := CompileJSONPath(expression); err == nil {
+			t.Errorf("CompileJSONPath(%q) succeeded, expected error", expression)
+		}
+	}
+}
+
+func TestItemFieldJSONValue(t *testing.T) {
+	data := map[string]interface{}{"tags": []interface{}{"x", "y"}}
+
+	tests := []struct {
+		field    ItemField
+		expected interface{}
+		fails    bool
+	}{
+		{ItemField{Name: "tag", JsonPath: "$.tags[0]"}, "x", false},
+		{ItemField{Name: "tags", JsonPath: "$.tags[*]", List: true}, []interface{}{"x", "y"}, false},
+		{ItemField{Name: "missing", JsonPath: "$.missing"}, nil, false},
+		{ItemField{Name: "missing", JsonPath: "$.missing", List: true}, []interface{}{}, false},
+		{ItemField{Name: "invalid", JsonPath: "$.tags[::0]"}, nil, true},
+	}
+
+	for _, test := range tests {
+		value, err := test.field.JSONValue(data)
+		if (err != nil) != test.fails {
+			t.Errorf("JSONValue of %s returned error %v", test.field.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("JSONValue of %s returned %v, expected %v", test.field.Name, value, test.expected)
+		}
+	}
+}
+
+func TestJSONPathCacheIsBounded(t *testing.T) {
+	for index := 0; index < JSONPATH_CACHE_SIZE+10; index++ {
+		if _, err := CompileJSONPath(fmt.Sprintf("$.items[%d]", index)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jsonPathCacheMutex.RLock()
+	defer jsonPathCacheMutex.RUnlock()
+	if len(jsonPathCache) > JSONPATH_CACHE_SIZE {
+		t.Errorf("Cache keeps %d expressions, expected at most %d", len(jsonPathCache), JSONPATH_CACHE_SIZE)
+	}
+}
@@ -0,0 +1,393 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	URL "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LoaderContext struct {
+	Proxy ScrapedItem
+	Field string
+}
+
+func (context *LoaderContext) BaseUrl() string {
+	if context.Proxy.FinalUrl != "" {
+		return context.Proxy.FinalUrl
+	}
+	return context.Proxy.Url
+}
+
+type Processor func(values []interface{}, context *LoaderContext) ([]interface{}, error)
+
+func mapStrings(values []interface{}, f func(string) (interface{}, error)) (result []interface{}, err error) {
+	result = make([]interface{}, 0, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			result = append(result, value)
+			continue
+		}
+		converted, err := f(s)
+		if err != nil {
+			return nil, err
+		}
+		if converted != nil {
+			result = append(result, converted)
+		}
+	}
+	return
+}
+
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return s == ""
+	}
+	return false
+}
+
+func Trim() Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		return mapStrings(values, func(s string) (interface{}, error) {
+			return strings.TrimSpace(s), nil
+		})
+	}
+}
+
+func Strip() Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		return mapStrings(values, func(s string) (interface{}, error) {
+			return StripString(s), nil
+		})
+	}
+}
+
+func DropEmpty() Processor {
+	return func(values []interface{}, context *LoaderContext) (result []interface{}, err error) {
+		for _, value := range values {
+			if !isEmptyValue(value) {
+				result = append(result, value)
+			}
+		}
+		return
+	}
+}
+
+func Join(separator string) Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		chunks := make([]string, 0, len(values))
+		for _, value := range values {
+			if !isEmptyValue(value) {
+				chunks = append(chunks, fmt.Sprint(value))
+			}
+		}
+		return []interface{}{strings.Join(chunks, separator)}, nil
+	}
+}
+
+func First() Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		for _, value := range values {
+			if !isEmptyValue(value) {
+				return []interface{}{value}, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+func AbsoluteURL() Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		base, err := URL.Parse(context.BaseUrl())
+		if err != nil {
+			return nil, err
+		}
+		return mapStrings(values, func(s string) (interface{}, error) {
+			reference, err := URL.Parse(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+			return base.ResolveReference(reference).String(), nil
+		})
+	}
+}
+
+func ParseNumber() Processor {
+	cleaner := strings.NewReplacer(",", "", " ", "", "\u00a0", "")
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		return mapStrings(values, func(s string) (interface{}, error) {
+			cleaned := cleaner.Replace(strings.TrimSpace(s))
+			if cleaned == "" {
+				return nil, nil
+			}
+			return strconv.ParseFloat(cleaned, 64)
+		})
+	}
+}
+
+func ParseDate(layouts ...string) Processor {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		return mapStrings(values, func(s string) (interface{}, error) {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				return nil, nil
+			}
+			for _, layout := range layouts {
+				if parsed, err := time.Parse(layout, s); err == nil {
+					return parsed, nil
+				}
+			}
+			return nil, errors.New(fmt.Sprintf("Cannot parse date %q", s))
+		})
+	}
+}
+
+func RegexCapture(expression string) Processor {
+	compiled, err := regexp.Compile(expression)
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return mapStrings(values, func(s string) (interface{}, error) {
+			match := compiled.FindStringSubmatch(s)
+			if match == nil {
+				return nil, nil
+			}
+			return match[len(match)-1], nil
+		})
+	}
+}
+
+func Default(value interface{}) Processor {
+	return func(values []interface{}, context *LoaderContext) ([]interface{}, error) {
+		for _, v := range values {
+			if !isEmptyValue(v) {
+				return values, nil
+			}
+		}
+		return []interface{}{value}, nil
+	}
+}
+
+func ParseProcessor(spec string) (processor Processor, err error) {
+	chunks := strings.SplitN(spec, ":", 2)
+	name := strings.ToLower(strings.TrimSpace(chunks[0]))
+	argument := ""
+	if len(chunks) == 2 {
+		argument = chunks[1]
+	}
+
+	switch name {
+	case "trim":
+		processor = Trim()
+	case "strip":
+		processor = Strip()
+	case "dropempty":
+		processor = DropEmpty()
+	case "join":
+		processor = Join(argument)
+	case "first":
+		processor = First()
+	case "absoluteurl":
+		processor = AbsoluteURL()
+	case "number":
+		processor = ParseNumber()
+	case "date":
+		if argument == "" {
+			processor = ParseDate()
+		} else {
+			processor = ParseDate(strings.Split(argument, "|")...)
+		}
+	case "regex":
+		if _, err = regexp.Compile(argument); err == nil {
+			processor = RegexCapture(argument)
+		}
+	case "default":
+		processor = Default(argument)
+	default:
+		err = errors.New(fmt.Sprintf("Unknown processor: %s", spec))
+	}
+	return
+}
+
+func ParseProcessors(specs []string) (processors []Processor, err error) {
+	for _, spec := range specs {
+		processor, err := ParseProcessor(spec)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return
+}
+
+func RunProcessors(values []interface{}, context *LoaderContext, processors ...Processor) (result []interface{}, err error) {
+	result = values
+	for _, processor := range processors {
+		if result, err = processor(result, context); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type LoaderSchema struct {
+	Kind          string
+	defaultInput  []Processor
+	defaultOutput []Processor
+	input         map[string][]Processor
+	output        map[string][]Processor
+}
+
+func (schema *LoaderSchema) SetDefaultInput(processors ...Processor) *LoaderSchema {
+	schema.defaultInput = processors
+	return schema
+}
+
+func (schema *LoaderSchema) SetDefaultOutput(processors ...Processor) *LoaderSchema {
+	schema.defaultOutput = processors
+	return schema
+}
+
+func (schema *LoaderSchema) SetInput(field string, processors ...Processor) *LoaderSchema {
+	schema.input[field] = processors
+	return schema
+}
+
+func (schema *LoaderSchema) SetOutput(field string, processors ...Processor) *LoaderSchema {
+	schema.output[field] = processors
+	return schema
+}
+
+func (schema *LoaderSchema) inputFor(field string) []Processor {
+	if processors, ok := schema.input[field]; ok {
+		return processors
+	}
+	return schema.defaultInput
+}
+
+func (schema *LoaderSchema) outputFor(field string) []Processor {
+	if processors, ok := schema.output[field]; ok {
+		return processors
+	}
+	return schema.defaultOutput
+}
+
+func (schema *LoaderSchema) NewLoader(proxy ScrapedItem, selection *goquery.Selection) *ItemLoader {
+	return &ItemLoader{
+		schema:    schema,
+		proxy:     proxy,
+		selection: selection,
+		values:    make(map[string][]interface{}),
+		errors:    &PopulateError{Errors: make(map[string]error)},
+	}
+}
+
+func NewLoaderSchema(kind string) (schema *LoaderSchema) {
+	schema = &LoaderSchema{
+		Kind:   kind,
+		input:  make(map[string][]Processor),
+		output: make(map[string][]Processor),
+	}
+	return
+}
+
+type ItemLoader struct {
+	schema    *LoaderSchema
+	proxy     ScrapedItem
+	selection *goquery.Selection
+	fields    []string
+	values    map[string][]interface{}
+	errors    *PopulateError
+}
+
+func (loader *ItemLoader) AddValue(field string, values ...interface{}) *ItemLoader {
+	if _, ok := loader.values[field]; !ok {
+		loader.fields = append(loader.fields, field)
+		loader.values[field] = []interface{}{}
+	}
+
+	context := &LoaderContext{Proxy: loader.proxy, Field: field}
+	processed, err := RunProcessors(values, context, loader.schema.inputFor(field)...)
+	if err != nil {
+		loader.errors.Add(field, err)
+		return loader
+	}
+	loader.values[field] = append(loader.values[field], processed...)
+	return loader
+}
+
+func (loader *ItemLoader) AddCSS(field string, selector string) *ItemLoader {
+	values := []interface{}{}
+	loader.selection.Find(selector).Each(func(i int, s *goquery.Selection) {
+		values = append(values, s.Text())
+	})
+	return loader.AddValue(field, values...)
+}
+
+func (loader *ItemLoader) AddCSSAttr(field string, selector string, attr string) *ItemLoader {
+	values := []interface{}{}
+	loader.selection.Find(selector).Each(func(i int, s *goquery.Selection) {
+		if value, ok := s.Attr(attr); ok {
+			values = append(values, value)
+		}
+	})
+	return loader.AddValue(field, values...)
+}
+
+func (loader *ItemLoader) AddXPath(field string, expression string) *ItemLoader {
+	values := []interface{}{}
+	if len(loader.selection.Nodes) > 0 {
+		nodes, err := NewHTMLXPathNode(loader.selection.Nodes[0]).Find(expression)
+		if err != nil {
+			loader.errors.Add(field, err)
+			return loader
+		}
+		for _, node := range nodes {
+			values = append(values, node.Text())
+		}
+	}
+	return loader.AddValue(field, values...)
+}
+
+func (loader *ItemLoader) Load() (item GenericItem, err error) {
+	item = NewGenericItem(loader.schema.Kind, loader.proxy)
+
+	for _, field := range loader.fields {
+		context := &LoaderContext{Proxy: loader.proxy, Field: field}
+		values, err := RunProcessors(loader.values[field], context, loader.schema.outputFor(field)...)
+		if err != nil {
+			loader.errors.Add(field, err)
+			continue
+		}
+
+		switch len(values) {
+		case 0:
+			item.Fields[field] = nil
+		case 1:
+			item.Fields[field] = values[0]
+		default:
+			item.Fields[field] = values
+		}
+	}
+
+	if len(loader.errors.Errors) > 0 {
+		err = loader.errors
+	}
+	return
+}
+
+func NewItemLoader(kind string, proxy ScrapedItem, selection *goquery.Selection) *ItemLoader {
+	return NewLoaderSchema(kind).NewLoader(proxy, selection)
+}
@@ -0,0 +1,137 @@
+package gotana
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProcessor(t *testing.T) {
+	context := &LoaderContext{Proxy: ScrapedItem{Url: "http://example.com/a", FinalUrl: "http://example.com/b/c"}}
+
+	tests := []struct {
+		spec     string
+		values   []interface{}
+		expected []interface{}
+	}{
+		{"trim", []interface{}{" a ", 1}, []interface{}{"a", 1}},
+		{"strip", []interface{}{" a \n b "}, []interface{}{"ab"}},
+		{"dropempty", []interface{}{"", "a", nil, 0}, []interface{}{"a", 0}},
+		{"join:, ", []interface{}{"a", "", "b"}, []interface{}{"a, b"}},
+		{"first", []interface{}{"", "a", "b"}, []interface{}{"a"}},
+		{"first", []interface{}{""}, nil},
+		{"absoluteurl", []interface{}{"../x", " /y", "http://other.com/"}, []interface{}{"http://example.com/x", "http://example.com/y", "http://other.com/"}},
+		{"number", []interface{}{"1,234.5", " ", "7"}, []interface{}{1234.5, 7.0}},
+		{"date:2006-01-02|02.01.2006", []interface{}{"2020-01-02", "03.02.2021"},
+			[]interface{}{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)}},
+		{"regex:([\\d.]+) zł", []interface{}{"1.5 zł", "none"}, []interface{}{"1.5"}},
+		{"default:n/a", []interface{}{"", nil}, []interface{}{"n/a"}},
+		{"default:n/a", []interface{}{"a"}, []interface{}{"a"}},
+		{" Trim ", []interface{}{" a "}, []interface{}{"a"}},
+	}
+
+	for _, test := range tests {
+		processor, err := ParseProcessor(test.spec)
+		if err != nil {
+			t.Errorf("ParseProcessor(%q) failed: %s", test.spec, err)
+			continue
+		}
+		result, err := processor(test.values, context)
+		if err != nil || !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s of %v returned %#v, %v, expected %#v", test.spec, test.values, result, err, test.expected)
+		}
+	}
+}
+
+func TestProcessorErrors(t *testing.T) {
+	for _, spec := range []string{"bogus", "regex:(", ""} {
+		if _, err := ParseProcessor(spec); err == nil {
+			t.Errorf("ParseProcessor(%q) succeeded, expected error", spec)
+		}
+	}
+
+	context := &LoaderContext{}
+	tests := []struct {
+		processor Processor
+		value     string
+	}{
+		{ParseNumber(), "12 EUR"},
+		{ParseDate("2006-01-02"), "yesterday"},
+		{RegexCapture("("), "x"},
+	}
+	for _, test := range tests {
+		if _, err := test.processor([]interface{}{test.value}, context); err == nil {
+			t.Errorf("Processor accepted %q", test.value)
+		}
+	}
+}
+
+func TestItemLoader(t *testing.T) {
+	const page = `<h1> Hello </h1><h1>World</h1><a href="../x">l</a><span class="p">1,234.5 zł</span>
+<span class="q">x</span><time>2020-01-02</time>`
+
+	proxy := ScrapedItem{Url: "http://example.com/b/c", FinalUrl: "http://example.com/b/c/"}
+	schema := NewLoaderSchema("page").SetDefaultInput(Trim()).SetDefaultOutput(First()).
+		SetOutput("title", Join(" ")).
+		SetInput("link", AbsoluteURL()).
+		SetInput("price", RegexCapture(`([\d,.]+)`), ParseNumber()).
+		SetInput("date", ParseDate("2006-01-02")).
+		SetOutput("tags", DropEmpty()).
+		SetOutput("missing", Default("n/a"))
+
+	item, err := schema.NewLoader(proxy, testSelection(t, page)).
+		AddCSS("title", "h1").
+		AddCSSAttr("link", "a", "href").
+		AddCSS("price", ".p").
+		AddXPath("date", "//time").
+		AddValue("tags", "a", " ", "b").
+		AddCSS("missing", ".none").
+		AddCSS("empty", ".none").
+		Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"title":   "Hello World",
+		"link":    "http://example.com/b/x",
+		"price":   1234.5,
+		"date":    time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		"tags":    []interface{}{"a", "b"},
+		"missing": "n/a",
+		"empty":   nil,
+	}
+	if !reflect.DeepEqual(item.Fields, expected) {
+		t.Errorf("Loaded %#v, expected %#v", item.Fields, expected)
+	}
+
+	_, err = schema.NewLoader(proxy, testSelection(t, page)).AddCSS("date", ".q").AddCSS("title", "h1").Load()
+	if populateErr, ok := err.(*PopulateError); !ok || len(populateErr.Errors) != 1 || populateErr.Errors["date"] == nil {
+		t.Errorf("Load returned %v, expected error of date field", err)
+	}
+}
+
+func TestItemFieldProcessors(t *testing.T) {
+	definition, err := NewItemDefinitionFromConfig(ItemConfig{Name: "product", Fields: []ItemFieldConfig{
+		{Name: "link", Selector: "a", Extract: "attr", Attr: "href", Processors: []string{"absoluteurl"}},
+		{Name: "price", Selector: ".p", Processors: []string{"regex:([\\d,.]+)", "number"}},
+		{Name: "tags", Selector: "i", List: true, Processors: []string{"trim", "dropempty"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := ScrapedItem{
+		Url:       "http://example.com/b/c/",
+		FinalUrl:  "http://example.com/b/c/",
+		BodyBytes: []byte(`<a href="../x">l</a><span class="p">1,234.5 zł</span><i> a </i><i> </i>`),
+	}
+	items, err := definition.Extract(proxy)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("Extract returned %v, %v", items, err)
+	}
+	expected := map[string]interface{}{"link": "http://example.com/b/x", "price": 1234.5, "tags": []interface{}{"a"}}
+	if fields := items[0].(GenericItem).Fields; !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Extracted %#v, expected %#v", fields, expected)
+	}
+}
@@ -12,6 +12,12 @@ type ScraperMeta struct {
 	failed     int
 	scraped    int
 	saved      int
+	noindex    int
+	nofollow   int
+	duplicates int
+	depths     map[int]int
+	stages     map[string]*StageStats
+	invalid    map[string]int
 }
 
 type EngineMeta struct {
@@ -37,6 +43,115 @@ func (meta *EngineMeta) IncrScraped(scraper *Scraper) {
 	stats.scraped += 1
 }
 
+func (meta *EngineMeta) IncrNoIndex(scraper *Scraper) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	stats.noindex += 1
+}
+
+func (meta *EngineMeta) IncrNoFollow(scraper *Scraper) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	stats.nofollow += 1
+}
+
+func (meta *EngineMeta) IncrDuplicates(scraper *Scraper) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	stats.duplicates += 1
+}
+
+func (meta *EngineMeta) IncrValidationErrors(scraper *Scraper, errs ValidationErrors) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	for _, err := range errs {
+		stats.invalid[err.Field] += 1
+	}
+}
+
+func (meta *EngineMeta) ValidationErrorCounts(scraper *Scraper) map[string]int {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	result := make(map[string]int, len(stats.invalid))
+	for field, count := range stats.invalid {
+		result[field] = count
+	}
+	return result
+}
+
+func (meta *EngineMeta) IncrDepth(scraper *Scraper, depth int) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	stats.depths[depth] += 1
+}
+
+func (meta *EngineMeta) DepthHistogram(scraper *Scraper) map[int]int {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	result := make(map[int]int, len(stats.depths))
+	for depth, count := range stats.depths {
+		result[depth] = count
+	}
+	return result
+}
+
+func (meta *EngineMeta) UpdateStageStats(scraper *Scraper, stage string, emitted int, reason string) {
+	if scraper == nil {
+		return
+	}
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats, ok := meta.ScraperStats[scraper.Name]
+	if !ok {
+		return
+	}
+	stageStats, ok := stats.stages[stage]
+	if !ok {
+		stageStats = NewStageStats()
+		stats.stages[stage] = stageStats
+	}
+
+	stageStats.Processed += 1
+	stageStats.Emitted += emitted
+	if reason != "" {
+		stageStats.Dropped += 1
+		stageStats.DropReasons[reason] += 1
+	}
+	if reason == DROP_REASON_ERROR {
+		stageStats.Errors += 1
+	}
+}
+
+func (meta *EngineMeta) PipelineStats(scraper *Scraper) map[string]StageStats {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	result := make(map[string]StageStats, len(stats.stages))
+	for name, stageStats := range stats.stages {
+		copied := *stageStats
+		copied.DropReasons = make(map[string]int, len(stageStats.DropReasons))
+		for reason, count := range stageStats.DropReasons {
+			copied.DropReasons[reason] = count
+		}
+		result[name] = copied
+	}
+	return result
+}
+
+func (meta *EngineMeta) RequestStats(scraper *Scraper) (crawled int, successful int, failed int) {
+	meta.statsMutex.Lock()
+	defer meta.statsMutex.Unlock()
+	stats := meta.ScraperStats[scraper.Name]
+	return stats.crawled, stats.successful, stats.failed
+}
+
 func (meta *EngineMeta) UpdateRequestStats(scraper *Scraper, isSuccessful bool, request *http.Request, response *http.Response) {
 	meta.statsMutex.Lock()
 	defer meta.statsMutex.Unlock()
@@ -60,6 +175,12 @@ func NewScraperMeta() (m *ScraperMeta) {
 		successful: 0,
 		scraped:    0,
 		saved:      0,
+		noindex:    0,
+		nofollow:   0,
+		duplicates: 0,
+		depths:     make(map[int]int),
+		stages:     make(map[string]*StageStats),
+		invalid:    make(map[string]int),
 	}
 	return
 }
@@ -8,6 +8,8 @@ import (
 
 type RequestMiddlewareFunc func(request *http.Request) *http.Request
 
+type ResponseMiddlewareFunc func(response *http.Response) *http.Response
+
 func DelAcceptEncodingMiddleware(request *http.Request) *http.Request {
 	request.Header.Del("Accept-Encoding")
 	return request
@@ -0,0 +1,279 @@
+package gotana
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"net/http"
+	URL "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type PaginationConfig struct {
+	NextSelector  string
+	LinkHeader    bool
+	Template      string
+	Start         *int
+	PageSize      int
+	CursorPath    string
+	CursorParam   string
+	MaxPages      int
+	EmptySelector string
+	EmptyPath     string
+}
+
+type Paginator struct {
+	NextSelector  string
+	LinkHeader    bool
+	Template      string
+	Start         int
+	PageSize      int
+	CursorPath    string
+	CursorParam   string
+	MaxPages      int
+	EmptySelector string
+	EmptyPath     string
+	templatePages *regexp.Regexp
+}
+
+func (paginator *Paginator) position(request ScheduledRequest) int {
+	if request.Page == 0 {
+		return 1
+	}
+	return request.Page
+}
+
+func (paginator *Paginator) pageNumber(request ScheduledRequest) int {
+	return paginator.Start + paginator.position(request) - 1
+}
+
+func (paginator *Paginator) inChain(request ScheduledRequest) bool {
+	return request.Page > 0
+}
+
+func (paginator *Paginator) isEmpty(proxy ScrapedItem) bool {
+	if paginator.EmptySelector != "" {
+		document, err := goquery.NewDocumentFromReader(bytes.NewReader(proxy.BodyBytes))
+		if err != nil || document.Find(paginator.EmptySelector).Length() == 0 {
+			return true
+		}
+	}
+
+	if paginator.EmptyPath != "" {
+		document, err := NewJSONDocument(bytes.NewReader(proxy.BodyBytes))
+		if err != nil {
+			return true
+		}
+		values, err := document.Query(paginator.EmptyPath)
+		if err != nil || len(values) == 0 {
+			return true
+		}
+		if len(values) == 1 {
+			if array, ok := values[0].([]interface{}); ok && len(array) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (paginator *Paginator) expandTemplate(page int, cursor string) string {
+	offset := (page - paginator.Start) * paginator.PageSize
+	replacer := strings.NewReplacer(
+		"{page}", strconv.Itoa(page),
+		"{offset}", strconv.Itoa(offset),
+		"{cursor}", URL.QueryEscape(cursor),
+	)
+	return replacer.Replace(paginator.Template)
+}
+
+func (paginator *Paginator) nextFromSelector(proxy ScrapedItem) string {
+	document, err := goquery.NewDocumentFromReader(bytes.NewReader(proxy.BodyBytes))
+	if err != nil {
+		return ""
+	}
+	href, _ := document.Find(paginator.NextSelector).First().Attr("href")
+	return strings.TrimSpace(href)
+}
+
+func (paginator *Paginator) nextFromCursor(request ScheduledRequest, proxy ScrapedItem, page int) string {
+	document, err := NewJSONDocument(bytes.NewReader(proxy.BodyBytes))
+	if err != nil {
+		return ""
+	}
+	values, err := document.Strings(paginator.CursorPath)
+	if err != nil || len(values) == 0 || values[0] == "" {
+		return ""
+	}
+	cursor := values[0]
+
+	if paginator.Template != "" {
+		return paginator.expandTemplate(page+1, cursor)
+	}
+
+	parsed, err := URL.Parse(request.Url)
+	if err != nil {
+		return ""
+	}
+	query := parsed.Query()
+	query.Set(paginator.CursorParam, cursor)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func (paginator *Paginator) Next(request ScheduledRequest, resp *http.Response, proxy ScrapedItem) (next ScheduledRequest, ok bool) {
+	page := paginator.pageNumber(request)
+	position := paginator.position(request)
+	if paginator.MaxPages > 0 && position >= paginator.MaxPages {
+		return
+	}
+	if paginator.isEmpty(proxy) {
+		return
+	}
+
+	url := ""
+	switch {
+	case paginator.CursorPath != "":
+		if paginator.inChain(request) {
+			url = paginator.nextFromCursor(request, proxy, page)
+		}
+	case paginator.Template != "":
+		if paginator.inChain(request) {
+			url = paginator.expandTemplate(page+1, "")
+		}
+	default:
+		if paginator.LinkHeader && resp != nil {
+			url = ParseLinkHeader(resp.Header.Get("Link"))["next"]
+		}
+		if url == "" && paginator.NextSelector != "" {
+			url = paginator.nextFromSelector(proxy)
+		}
+	}
+
+	if url == "" {
+		return
+	}
+
+	resolved, err := resolveURL(proxy.FinalUrl, url)
+	if err != nil || resolved == request.Url {
+		return
+	}
+
+	next = ScheduledRequest{Url: resolved, Depth: request.Depth, Page: position + 1}
+	ok = true
+	return
+}
+
+func (paginator *Paginator) Owns(url string) bool {
+	parsed, err := URL.Parse(url)
+	if err != nil {
+		return false
+	}
+
+	if paginator.templatePages != nil {
+		if !strings.Contains(paginator.Template, "://") {
+			url = parsed.RequestURI()
+		}
+		return paginator.templatePages.MatchString(url)
+	}
+	if paginator.CursorPath != "" {
+		_, ok := parsed.Query()[paginator.CursorParam]
+		return ok
+	}
+	return false
+}
+
+func (paginator *Paginator) String() string {
+	return fmt.Sprintf("<Paginator>. Max pages: %d", paginator.MaxPages)
+}
+
+func resolveURL(base string, reference string) (string, error) {
+	baseUrl, err := URL.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	referenceUrl, err := URL.Parse(reference)
+	if err != nil {
+		return "", err
+	}
+	return baseUrl.ResolveReference(referenceUrl).String(), nil
+}
+
+func ParseLinkHeader(header string) (links map[string]string) {
+	links = make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		sections := strings.Split(part, ";")
+		target := strings.TrimSpace(sections[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		target = target[1 : len(target)-1]
+
+		for _, section := range sections[1:] {
+			pair := strings.SplitN(strings.TrimSpace(section), "=", 2)
+			if len(pair) != 2 || strings.ToLower(pair[0]) != "rel" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(pair[1], `"`)) {
+				links[strings.ToLower(rel)] = target
+			}
+		}
+	}
+	return
+}
+
+func templatePagesRegexp(template string) *regexp.Regexp {
+	expression := regexp.QuoteMeta(template)
+	expression = strings.NewReplacer(
+		regexp.QuoteMeta("{page}"), `-?\d+`,
+		regexp.QuoteMeta("{offset}"), `-?\d+`,
+		regexp.QuoteMeta("{cursor}"), `[^&#]*`,
+	).Replace(expression)
+	return regexp.MustCompile("^" + expression + "$")
+}
+
+func NewPaginator(config PaginationConfig) (paginator *Paginator, err error) {
+	paginator = &Paginator{
+		NextSelector:  config.NextSelector,
+		LinkHeader:    config.LinkHeader,
+		Template:      config.Template,
+		Start:         1,
+		PageSize:      config.PageSize,
+		CursorPath:    config.CursorPath,
+		CursorParam:   config.CursorParam,
+		MaxPages:      config.MaxPages,
+		EmptySelector: config.EmptySelector,
+		EmptyPath:     config.EmptyPath,
+	}
+
+	if config.Start != nil {
+		paginator.Start = *config.Start
+	}
+	if config.Template != "" {
+		paginator.templatePages = templatePagesRegexp(config.Template)
+	}
+
+	for _, path := range []string{config.CursorPath, config.EmptyPath} {
+		if path == "" {
+			continue
+		}
+		if _, err = CompileJSONPath(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.CursorPath != "" && config.CursorParam == "" && !strings.Contains(config.Template, "{cursor}") {
+		return nil, errors.New("Cursor pagination requires cursorparam or {cursor} in template")
+	}
+	if config.Template != "" && config.CursorPath == "" && !strings.Contains(config.Template, "{page}") && !strings.Contains(config.Template, "{offset}") {
+		return nil, errors.New(fmt.Sprintf("Pagination template %s has no {page} or {offset} placeholder", config.Template))
+	}
+	return
+}
+
+func (config PaginationConfig) Enabled() bool {
+	return config.NextSelector != "" || config.LinkHeader || config.Template != "" || config.CursorPath != ""
+}
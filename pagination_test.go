@@ -0,0 +1,152 @@
+package gotana
+
+import (
+	"net/http"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestPaginatorNext(t *testing.T) {
+	const api = "http://example.com/api"
+
+	tests := []struct {
+		name     string
+		config   PaginationConfig
+		request  ScheduledRequest
+		header   string
+		body     string
+		expected string
+		page     int
+	}{
+		{"template", PaginationConfig{Template: api + "?page={page}&offset={offset}", PageSize: 20},
+			ScheduledRequest{Url: api + "?page=1&offset=0", Page: 1}, "", `{}`, api + "?page=2&offset=20", 2},
+		{"template outside chain", PaginationConfig{Template: api + "?page={page}"},
+			ScheduledRequest{Url: api + "?page=5"}, "", `{}`, "", 0},
+		{"max pages", PaginationConfig{Template: api + "?page={page}", MaxPages: 3},
+			ScheduledRequest{Url: api + "?page=3", Page: 3}, "", `{}`, "", 0},
+		{"below max pages", PaginationConfig{Template: api + "?page={page}", MaxPages: 3},
+			ScheduledRequest{Url: api + "?page=2", Page: 2}, "", `{}`, api + "?page=3", 3},
+		{"empty path", PaginationConfig{Template: api + "?page={page}", EmptyPath: "$.items[*]"},
+			ScheduledRequest{Url: api + "?page=1", Page: 1}, "", `{"items":[]}`, "", 0},
+		{"empty selector", PaginationConfig{NextSelector: "a.next", EmptySelector: ".item"},
+			ScheduledRequest{Url: "http://example.com/list"}, "", `<a class="next" href="/list?page=2">next</a>`, "", 0},
+		{"cursor param", PaginationConfig{CursorPath: "$.next", CursorParam: "cursor"},
+			ScheduledRequest{Url: api + "?q=1", Page: 1}, "", `{"next":"ab c"}`, api + "?cursor=ab+c&q=1", 2},
+		{"cursor template", PaginationConfig{CursorPath: "$.next", Template: api + "?after={cursor}"},
+			ScheduledRequest{Url: api, Page: 1}, "", `{"next":"x/y"}`, api + "?after=x%2Fy", 2},
+		{"missing cursor", PaginationConfig{CursorPath: "$.next", CursorParam: "cursor"},
+			ScheduledRequest{Url: api, Page: 1}, "", `{"next":""}`, "", 0},
+		{"link header", PaginationConfig{LinkHeader: true},
+			ScheduledRequest{Url: "http://example.com/p1"}, `<http://example.com/p2>; rel="next"`, ``, "http://example.com/p2", 2},
+		{"relative next link", PaginationConfig{NextSelector: "a.next"},
+			ScheduledRequest{Url: "http://example.com/list/p1", Page: 1}, "", `<a class="next" href="p2">next</a>`, "http://example.com/list/p2", 2},
+		{"next link to itself", PaginationConfig{NextSelector: "a.next"},
+			ScheduledRequest{Url: "http://example.com/p1", Page: 1}, "", `<a class="next" href="/p1">next</a>`, "", 0},
+	}
+
+	for _, test := range tests {
+		paginator, err := NewPaginator(test.config)
+		if err != nil {
+			t.Errorf("%s: NewPaginator failed: %s", test.name, err)
+			continue
+		}
+		response := &http.Response{Header: http.Header{}}
+		if test.header != "" {
+			response.Header.Set("Link", test.header)
+		}
+		proxy := ScrapedItem{Url: test.request.Url, FinalUrl: test.request.Url, BodyBytes: []byte(test.body)}
+
+		next, ok := paginator.Next(test.request, response, proxy)
+		if ok != (test.expected != "") || next.Url != test.expected || next.Page != test.page {
+			t.Errorf("%s: Next returned %+v, %v, expected %s on page %d", test.name, next, ok, test.expected, test.page)
+		}
+	}
+}
+
+func TestPaginatorZeroBasedStart(t *testing.T) {
+	config := ScraperConfig{}
+	data := `
+scrapers:
+- name: api
+  url: http://example.com/api?page=0
+  pagination:
+    template: http://example.com/api?page={page}&offset={offset}
+    start: 0
+    pagesize: 10
+    maxpages: 2
+`
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	paginator, err := NewPaginator(config.Scrapers[0].Pagination)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := ScheduledRequest{Url: "http://example.com/api?page=0", Page: 1}
+	next, ok := paginator.Next(first, nil, ScrapedItem{FinalUrl: first.Url})
+	if !ok || next.Url != "http://example.com/api?page=1&offset=10" || paginator.pageNumber(next) != 1 {
+		t.Fatalf("Next of first page returned %+v, %v", next, ok)
+	}
+	if next, ok = paginator.Next(next, nil, ScrapedItem{FinalUrl: next.Url}); ok {
+		t.Errorf("Next returned %+v after max pages", next)
+	}
+
+	if paginator, _ = NewPaginator(PaginationConfig{Template: "http://example.com/api?page={page}"}); paginator.Start != 1 {
+		t.Errorf("Paginator without start begins at %d, expected 1", paginator.Start)
+	}
+}
+
+func TestPaginatorOwns(t *testing.T) {
+	tests := []struct {
+		config   PaginationConfig
+		url      string
+		expected bool
+	}{
+		{PaginationConfig{Template: "http://example.com/list?page={page}"}, "http://example.com/list?page=12", true},
+		{PaginationConfig{Template: "http://example.com/list?page={page}"}, "http://example.com/list?page=12&sort=asc", false},
+		{PaginationConfig{Template: "http://example.com/list?page={page}"}, "http://example.com/item/12", false},
+		{PaginationConfig{Template: "/list?offset={offset}"}, "http://example.com/list?offset=20", true},
+		{PaginationConfig{CursorPath: "$.next", Template: "/api?after={cursor}"}, "http://example.com/api?after=abc", true},
+		{PaginationConfig{CursorPath: "$.next", CursorParam: "cursor"}, "http://example.com/api?cursor=abc", true},
+		{PaginationConfig{CursorPath: "$.next", CursorParam: "cursor"}, "http://example.com/api?q=abc", false},
+		{PaginationConfig{NextSelector: "a.next"}, "http://example.com/list?page=2", false},
+	}
+
+	for _, test := range tests {
+		paginator, err := NewPaginator(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owns := paginator.Owns(test.url); owns != test.expected {
+			t.Errorf("Paginator %+v owns %s: %v, expected %v", test.config, test.url, owns, test.expected)
+		}
+	}
+}
+
+func TestNewPaginatorErrors(t *testing.T) {
+	for _, config := range []PaginationConfig{
+		{Template: "http://example.com/list"},
+		{CursorPath: "$.next"},
+		{CursorPath: "$.", CursorParam: "cursor"},
+		{NextSelector: "a", EmptyPath: "$["},
+	} {
+		if _, err := NewPaginator(config); err == nil {
+			t.Errorf("NewPaginator(%+v) succeeded, expected error", config)
+		}
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<http://example.com/p3>; rel="next last", <http://example.com/p1>; rel=prev, broken; rel=first`)
+	expected := map[string]string{"next": "http://example.com/p3", "last": "http://example.com/p3", "prev": "http://example.com/p1"}
+	if len(links) != len(expected) {
+		t.Errorf("ParseLinkHeader returned %v, expected %v", links, expected)
+	}
+	for rel, url := range expected {
+		if links[rel] != url {
+			t.Errorf("ParseLinkHeader returned %s for %s, expected %s", links[rel], rel, url)
+		}
+	}
+}
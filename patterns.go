@@ -1,41 +1,265 @@
 package gotana
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 )
 
 const TYPE_CONTAINS = "contains"
 const TYPE_REGEXP = "regexp"
+const TYPE_PATH = "path"
+const TYPE_GLOB = "glob"
+const TYPE_PREFIX = "prefix"
+const TYPE_HOST = "host"
+const TYPE_QUERY = "query"
+
+var pathParameterRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
 
 type URLPattern struct {
-	Type    string `required:"true"`
-	Pattern string `required:"true"`
+	Type     string `required:"true"`
+	Pattern  string `required:"true"`
+	Exclude  bool
+	compiled *regexp.Regexp
 }
 
 func (item URLPattern) String() (result string) {
-	result = fmt.Sprintf("URL Pattern [%s]: %s", item.Type, item.Pattern)
+	if item.Exclude {
+		result = fmt.Sprintf("URL Pattern [not %s]: %s", item.Type, item.Pattern)
+	} else {
+		result = fmt.Sprintf("URL Pattern [%s]: %s", item.Type, item.Pattern)
+	}
 	return
 }
 
 func (item *URLPattern) Validate(url string) (result bool) {
-	switch {
-	case item.Type == TYPE_CONTAINS:
-		result = strings.Contains(url, item.Pattern)
-	case item.Type == TYPE_REGEXP:
-		matched, _ := regexp.MatchString(item.Pattern, url)
-		result = matched
+	result, _ = item.Match(url)
+	return
+}
+
+func (item *URLPattern) Match(url string) (result bool, params map[string]string) {
+	result, params = item.matchRaw(url)
+	if item.Exclude {
+		return !result, nil
+	}
+	return
+}
+
+func (item *URLPattern) matchRaw(rawUrl string) (result bool, params map[string]string) {
+	switch item.Type {
+	case TYPE_CONTAINS:
+		result = strings.Contains(rawUrl, item.Pattern)
+	case TYPE_PREFIX:
+		result = strings.HasPrefix(rawUrl, item.Pattern)
+	case TYPE_REGEXP, TYPE_GLOB:
+		result, params = matchRegexp(item.regexp(), rawUrl)
+	case TYPE_PATH:
+		result, params = matchRegexp(item.regexp(), urlPath(rawUrl))
+	case TYPE_HOST:
+		result = matchHost(item.Pattern, urlHost(rawUrl))
+	case TYPE_QUERY:
+		result = matchQuery(item.Pattern, item.regexp(), rawUrl)
 	default:
 		result = false
 	}
 	return
 }
 
-func NewURLPattern(kind string, pattern string) (instance URLPattern) {
+func (item *URLPattern) regexp() *regexp.Regexp {
+	if item.compiled != nil {
+		return item.compiled
+	}
+	compiled, _ := compileURLPattern(item.Type, item.Pattern)
+	return compiled
+}
+
+func matchRegexp(expression *regexp.Regexp, s string) (result bool, params map[string]string) {
+	if expression == nil {
+		return
+	}
+
+	match := expression.FindStringSubmatch(s)
+	if match == nil {
+		return
+	}
+
+	result = true
+	for index, name := range expression.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = match[index]
+	}
+	return
+}
+
+func matchHost(pattern string, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
+
+func matchQuery(pattern string, expression *regexp.Regexp, rawUrl string) bool {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+
+	key, _, hasValue := splitQueryPattern(pattern)
+	values, ok := parsed.Query()[key]
+	if !ok {
+		return false
+	}
+	if !hasValue {
+		return true
+	}
+	if expression == nil {
+		return false
+	}
+
+	for _, candidate := range values {
+		if expression.MatchString(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitQueryPattern(pattern string) (key string, value string, hasValue bool) {
+	chunks := strings.SplitN(pattern, "=", 2)
+	key = chunks[0]
+	if len(chunks) == 2 {
+		value = chunks[1]
+		hasValue = true
+	}
+	return
+}
+
+func urlPath(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	if parsed.Path == "" {
+		return "/"
+	}
+	return parsed.Path
+}
+
+func urlHost(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+func pathTemplateToRegexp(template string) string {
+	expression := ""
+	last := 0
+	for _, indices := range pathParameterRegexp.FindAllStringSubmatchIndex(template, -1) {
+		expression += regexp.QuoteMeta(template[last:indices[0]])
+		expression += fmt.Sprintf("(?P<%s>[^/]+)", template[indices[2]:indices[3]])
+		last = indices[1]
+	}
+	expression += regexp.QuoteMeta(strings.TrimSuffix(template[last:], "/"))
+	return "^" + expression + "/?$"
+}
+
+func globToRegexp(glob string) string {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				expression.WriteString(".*")
+				i++
+			} else {
+				expression.WriteString("[^/?#]*")
+			}
+		case '?':
+			expression.WriteString("[^/]")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+	expression.WriteString("$")
+	return expression.String()
+}
+
+func patternExpression(kind string, pattern string) (expression string, ok bool) {
+	switch kind {
+	case TYPE_REGEXP:
+		return pattern, true
+	case TYPE_PATH:
+		return pathTemplateToRegexp(pattern), true
+	case TYPE_GLOB:
+		return globToRegexp(pattern), true
+	}
+	return
+}
+
+func compileURLPattern(kind string, pattern string) (compiled *regexp.Regexp, err error) {
+	switch kind {
+	case TYPE_CONTAINS, TYPE_PREFIX, TYPE_HOST:
+		if pattern == "" {
+			err = errors.New(fmt.Sprintf("Empty %s pattern", kind))
+		}
+		return
+	case TYPE_QUERY:
+		key, value, hasValue := splitQueryPattern(pattern)
+		if key == "" {
+			err = errors.New(fmt.Sprintf("Query pattern %s has no parameter name", pattern))
+		} else if hasValue {
+			compiled, err = regexp.Compile(globToRegexp(value))
+		}
+		return
+	}
+
+	expression, ok := patternExpression(kind, pattern)
+	if !ok {
+		err = errors.New(fmt.Sprintf("Unknown pattern type: %s", kind))
+		return
+	}
+
+	compiled, err = regexp.Compile(expression)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Cannot compile %s pattern %s: %s", kind, pattern, err))
+	}
+	return
+}
+
+func CompileURLPattern(kind string, pattern string, exclude bool) (instance URLPattern, err error) {
+	compiled, err := compileURLPattern(kind, pattern)
 	instance = URLPattern{
-		Type:    kind,
-		Pattern: pattern,
+		Type:     kind,
+		Pattern:  pattern,
+		Exclude:  exclude,
+		compiled: compiled,
+	}
+	return
+}
+
+func NewURLPattern(kind string, pattern string) (instance URLPattern) {
+	instance, err := CompileURLPattern(kind, pattern, false)
+	if err != nil {
+		Logger().Warning(err.Error())
+	}
+	return
+}
+
+func NewExcludeURLPattern(kind string, pattern string) (instance URLPattern) {
+	instance, err := CompileURLPattern(kind, pattern, true)
+	if err != nil {
+		Logger().Warning(err.Error())
 	}
 	return
 }
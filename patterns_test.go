@@ -0,0 +1,78 @@
+package gotana
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURLPatternMatch(t *testing.T) {
+	tests := []struct {
+		kind     string
+		pattern  string
+		url      string
+		expected bool
+		params   map[string]string
+	}{
+		{TYPE_CONTAINS, "/blog/", "https://example.com/blog/post", true, nil},
+		{TYPE_CONTAINS, "/blog/", "https://example.com/news/post", false, nil},
+		{TYPE_PREFIX, "https://example.com/blog", "https://example.com/blog/post", true, nil},
+		{TYPE_PREFIX, "https://example.com/blog", "http://example.com/blog/post", false, nil},
+		{TYPE_REGEXP, `/(?P<year>\d{4})/`, "https://example.com/2017/post", true, map[string]string{"year": "2017"}},
+		{TYPE_REGEXP, `/(?P<year>\d{4})/`, "https://example.com/17/post", false, nil},
+		{TYPE_PATH, "/issues/{id}", "https://example.com/issues/12?page=1", true, map[string]string{"id": "12"}},
+		{TYPE_PATH, "/issues/{id}/", "https://example.com/issues/12", true, map[string]string{"id": "12"}},
+		{TYPE_PATH, "/issues/{id}", "https://example.com/issues/12/comments", false, nil},
+		{TYPE_PATH, "/", "https://example.com", true, nil},
+		{TYPE_GLOB, "https://example.com/*/item-*", "https://example.com/cat/item-3", true, nil},
+		{TYPE_GLOB, "https://example.com/*/item-*", "https://example.com/cat/sub/item-3", false, nil},
+		{TYPE_GLOB, "https://example.com/**/item-*", "https://example.com/cat/sub/item-3", true, nil},
+		{TYPE_GLOB, "https://example.com/*", "https://example.com/list?page=2", false, nil},
+		{TYPE_GLOB, "https://example.com/page-?", "https://example.com/page-2", true, nil},
+		{TYPE_GLOB, "https://example.com/a?b", "https://example.com/a/b", false, nil},
+		{TYPE_GLOB, "https://example.com/a.b", "https://example.com/axb", false, nil},
+		{TYPE_HOST, "example.com", "https://EXAMPLE.com/post", true, nil},
+		{TYPE_HOST, "example.com", "https://www.example.com/post", false, nil},
+		{TYPE_HOST, "*.example.com", "https://www.example.com/post", true, nil},
+		{TYPE_HOST, "*.example.com", "https://example.com/post", false, nil},
+		{TYPE_HOST, "*.example.com", "https://badexample.com/post", false, nil},
+		{TYPE_QUERY, "page", "https://example.com/?page=2", true, nil},
+		{TYPE_QUERY, "page", "https://example.com/?pages=2", false, nil},
+		{TYPE_QUERY, "page=1*", "https://example.com/?page=12", true, nil},
+		{TYPE_QUERY, "page=1*", "https://example.com/?page=2&page=10", true, nil},
+		{TYPE_QUERY, "page=1*", "https://example.com/?page=2", false, nil},
+		{"unknown", "x", "https://example.com/x", false, nil},
+	}
+
+	for _, test := range tests {
+		pattern := NewURLPattern(test.kind, test.pattern)
+		result, params := pattern.Match(test.url)
+		if result != test.expected || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s matching %s returned %v %v, expected %v %v", pattern, test.url, result, params, test.expected, test.params)
+		}
+
+		excluded := NewExcludeURLPattern(test.kind, test.pattern)
+		if result, params = excluded.Match(test.url); result == test.expected || params != nil {
+			t.Errorf("%s matching %s returned %v %v", excluded, test.url, result, params)
+		}
+	}
+}
+
+func TestCompileURLPatternErrors(t *testing.T) {
+	tests := []struct {
+		kind    string
+		pattern string
+	}{
+		{TYPE_REGEXP, "(["},
+		{TYPE_CONTAINS, ""},
+		{TYPE_PREFIX, ""},
+		{TYPE_HOST, ""},
+		{TYPE_QUERY, "=1"},
+		{"unknown", "x"},
+	}
+
+	for _, test := range tests {
+		if _, err := CompileURLPattern(test.kind, test.pattern, false); err == nil {
+			t.Errorf("CompileURLPattern(%s, %q) succeeded, expected error", test.kind, test.pattern)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package gotana
+
+import (
+	"regexp"
+	"strings"
+)
+
+type prefixTrie struct {
+	children map[byte]*prefixTrie
+	terminal bool
+}
+
+func (trie *prefixTrie) Insert(prefix string) {
+	node := trie
+	for i := 0; i < len(prefix); i++ {
+		child, ok := node.children[prefix[i]]
+		if !ok {
+			child = &prefixTrie{children: make(map[byte]*prefixTrie)}
+			node.children[prefix[i]] = child
+		}
+		node = child
+	}
+	node.terminal = true
+}
+
+func (trie *prefixTrie) MatchesPrefixOf(s string) bool {
+	node := trie
+	for i := 0; i < len(s); i++ {
+		if node.terminal {
+			return true
+		}
+		child, ok := node.children[s[i]]
+		if !ok {
+			return false
+		}
+		node = child
+	}
+	return node.terminal
+}
+
+type patternMatcher struct {
+	size            int
+	contains        []string
+	prefixes        *prefixTrie
+	hosts           map[string]bool
+	hostSuffixes    map[string]bool
+	urlExpressions  []string
+	pathExpressions []string
+	urlRegexp       *regexp.Regexp
+	pathRegexp      *regexp.Regexp
+	queryPatterns   []URLPattern
+	fallback        []URLPattern
+}
+
+func (matcher *patternMatcher) Add(pattern URLPattern) {
+	matcher.size += 1
+	switch pattern.Type {
+	case TYPE_CONTAINS:
+		matcher.contains = append(matcher.contains, pattern.Pattern)
+	case TYPE_PREFIX:
+		matcher.prefixes.Insert(pattern.Pattern)
+	case TYPE_HOST:
+		host := strings.ToLower(pattern.Pattern)
+		if strings.HasPrefix(host, "*.") {
+			matcher.hostSuffixes[host[2:]] = true
+		} else {
+			matcher.hosts[host] = true
+		}
+	case TYPE_QUERY:
+		matcher.queryPatterns = append(matcher.queryPatterns, pattern)
+	default:
+		matcher.fallback = append(matcher.fallback, pattern)
+	}
+}
+
+func (matcher *patternMatcher) Compile() {
+	urlExpressions, pathExpressions := matcher.urlExpressions, matcher.pathExpressions
+	var remaining []URLPattern
+
+	for _, pattern := range matcher.fallback {
+		expression, ok := patternExpression(pattern.Type, pattern.Pattern)
+		if !ok || pattern.compiled == nil {
+			remaining = append(remaining, pattern)
+			continue
+		}
+		if pattern.Type == TYPE_PATH {
+			pathExpressions = append(pathExpressions, "(?:"+expression+")")
+		} else {
+			urlExpressions = append(urlExpressions, "(?:"+expression+")")
+		}
+	}
+	if len(remaining) == len(matcher.fallback) {
+		return
+	}
+
+	urlRegexp, pathRegexp := matcher.urlRegexp, matcher.pathRegexp
+	if len(urlExpressions) > len(matcher.urlExpressions) {
+		if urlRegexp = compileAlternation(urlExpressions); urlRegexp == nil {
+			return
+		}
+	}
+	if len(pathExpressions) > len(matcher.pathExpressions) {
+		if pathRegexp = compileAlternation(pathExpressions); pathRegexp == nil {
+			return
+		}
+	}
+
+	matcher.urlExpressions, matcher.pathExpressions = urlExpressions, pathExpressions
+	matcher.urlRegexp, matcher.pathRegexp = urlRegexp, pathRegexp
+	matcher.fallback = remaining
+}
+
+func (matcher *patternMatcher) Matches(rawUrl string) bool {
+	for _, s := range matcher.contains {
+		if strings.Contains(rawUrl, s) {
+			return true
+		}
+	}
+
+	if matcher.prefixes.MatchesPrefixOf(rawUrl) {
+		return true
+	}
+
+	if len(matcher.hosts) > 0 || len(matcher.hostSuffixes) > 0 {
+		host := urlHost(rawUrl)
+		if matcher.hosts[host] {
+			return true
+		}
+		for index := strings.Index(host, "."); index != -1; index = strings.Index(host, ".") {
+			host = host[index+1:]
+			if matcher.hostSuffixes[host] {
+				return true
+			}
+		}
+	}
+
+	if matcher.urlRegexp != nil && matcher.urlRegexp.MatchString(rawUrl) {
+		return true
+	}
+	if matcher.pathRegexp != nil && matcher.pathRegexp.MatchString(urlPath(rawUrl)) {
+		return true
+	}
+
+	for index := range matcher.queryPatterns {
+		if ok, _ := matcher.queryPatterns[index].matchRaw(rawUrl); ok {
+			return true
+		}
+	}
+	for index := range matcher.fallback {
+		if ok, _ := matcher.fallback[index].matchRaw(rawUrl); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func compileAlternation(expressions []string) *regexp.Regexp {
+	if len(expressions) == 0 {
+		return nil
+	}
+	compiled, err := regexp.Compile(strings.Join(expressions, "|"))
+	if err != nil {
+		return nil
+	}
+	return compiled
+}
+
+func newPatternMatcher() *patternMatcher {
+	return &patternMatcher{
+		prefixes:     &prefixTrie{children: make(map[byte]*prefixTrie)},
+		hosts:        make(map[string]bool),
+		hostSuffixes: make(map[string]bool),
+	}
+}
+
+type URLPatternSet struct {
+	patterns []URLPattern
+	include  *patternMatcher
+	exclude  *patternMatcher
+}
+
+func (set *URLPatternSet) Add(patterns ...URLPattern) *URLPatternSet {
+	for _, pattern := range patterns {
+		if pattern.compiled == nil {
+			pattern.compiled, _ = compileURLPattern(pattern.Type, pattern.Pattern)
+		}
+		set.patterns = append(set.patterns, pattern)
+		if pattern.Exclude {
+			set.exclude.Add(pattern)
+		} else {
+			set.include.Add(pattern)
+		}
+	}
+
+	set.include.Compile()
+	set.exclude.Compile()
+	return set
+}
+
+func (set *URLPatternSet) Patterns() []URLPattern {
+	return set.patterns
+}
+
+func (set *URLPatternSet) Len() int {
+	return len(set.patterns)
+}
+
+func (set *URLPatternSet) Validate(rawUrl string) bool {
+	if len(set.patterns) == 0 {
+		return true
+	}
+	if set.exclude.size > 0 && set.exclude.Matches(rawUrl) {
+		return false
+	}
+	if set.include.size == 0 {
+		return true
+	}
+	return set.include.Matches(rawUrl)
+}
+
+func NewURLPatternSet(patterns ...URLPattern) (set *URLPatternSet) {
+	set = &URLPatternSet{include: newPatternMatcher(), exclude: newPatternMatcher()}
+	set.Add(patterns...)
+	return
+}
@@ -0,0 +1,121 @@
+package gotana
+
+import (
+	"fmt"
+	"testing"
+)
+
+var patternSetUrls = []string{
+	"https://example.com/",
+	"https://example.com/blog/post-1",
+	"https://example.com/blog/private/post-2",
+	"https://www.example.com/blog/post-3?page=2",
+	"https://shop.example.com/cat/item-3",
+	"https://shop.example.com/cat/sub/item-4",
+	"https://example.com/issues/12",
+	"https://example.com/issues/12/comments",
+	"https://example.com/2017/07/news.html",
+	"https://other.com/list?page=12&sort=asc",
+	"https://other.com/list?page=2",
+	"https://other.com/a/b",
+	"https://other.com/axb",
+	"http://EXAMPLE.com/Blog",
+}
+
+var patternSetPatterns = []struct {
+	kind    string
+	pattern string
+}{
+	{TYPE_CONTAINS, "/blog/"},
+	{TYPE_CONTAINS, "private"},
+	{TYPE_PREFIX, "https://example.com/issues/"},
+	{TYPE_PREFIX, "https://other.com/"},
+	{TYPE_REGEXP, `/(?P<year>\d{4})/(?P<month>\d{2})/`},
+	{TYPE_REGEXP, `(?i)blog$`},
+	{TYPE_PATH, "/issues/{id}"},
+	{TYPE_PATH, "/cat/{category}/item-4"},
+	{TYPE_GLOB, "https://*.example.com/*/item-*"},
+	{TYPE_GLOB, "https://other.com/a?b"},
+	{TYPE_HOST, "example.com"},
+	{TYPE_HOST, "*.example.com"},
+	{TYPE_QUERY, "sort"},
+	{TYPE_QUERY, "page=1*"},
+}
+
+func naiveValidate(patterns []URLPattern, url string) bool {
+	included, hasIncludes := false, false
+	for index := range patterns {
+		ok, _ := patterns[index].Match(url)
+		if patterns[index].Exclude {
+			if !ok {
+				return false
+			}
+			continue
+		}
+		hasIncludes = true
+		included = included || ok
+	}
+	return included || !hasIncludes
+}
+
+func checkPatternSet(t *testing.T, name string, set *URLPatternSet, patterns []URLPattern) {
+	for _, url := range patternSetUrls {
+		if expected, result := naiveValidate(patterns, url), set.Validate(url); result != expected {
+			t.Errorf("%s: Validate(%s) returned %v, expected %v", name, url, result, expected)
+		}
+	}
+}
+
+func TestURLPatternSetSinglePatterns(t *testing.T) {
+	for _, item := range patternSetPatterns {
+		for _, pattern := range []URLPattern{NewURLPattern(item.kind, item.pattern), NewExcludeURLPattern(item.kind, item.pattern)} {
+			checkPatternSet(t, pattern.String(), NewURLPatternSet(pattern), []URLPattern{pattern})
+		}
+	}
+}
+
+func TestURLPatternSetCombined(t *testing.T) {
+	var includes, excludes []URLPattern
+	for _, item := range patternSetPatterns {
+		includes = append(includes, NewURLPattern(item.kind, item.pattern))
+		excludes = append(excludes, NewExcludeURLPattern(item.kind, item.pattern))
+	}
+
+	for index := range patternSetPatterns {
+		patterns := append(append([]URLPattern{}, includes[:index]...), includes[index+1:]...)
+		patterns = append(patterns, excludes[index])
+		checkPatternSet(t, fmt.Sprintf("all but %s", excludes[index]), NewURLPatternSet(patterns...), patterns)
+	}
+	checkPatternSet(t, "includes", NewURLPatternSet(includes...), includes)
+	checkPatternSet(t, "excludes", NewURLPatternSet(excludes...), excludes)
+	checkPatternSet(t, "empty", NewURLPatternSet(), nil)
+}
+
+func TestURLPatternSetIncrementalAdd(t *testing.T) {
+	set := NewURLPatternSet()
+	var patterns []URLPattern
+	for index, item := range patternSetPatterns {
+		pattern := URLPattern{Type: item.kind, Pattern: item.pattern, Exclude: index%5 == 4}
+		set.Add(pattern)
+		patterns = append(patterns, pattern)
+		checkPatternSet(t, fmt.Sprintf("after %s", pattern), set, patterns)
+	}
+
+	invalid := URLPattern{Type: TYPE_REGEXP, Pattern: "(["}
+	set.Add(invalid)
+	patterns = append(patterns, invalid)
+	checkPatternSet(t, "after invalid regexp", set, patterns)
+
+	for index := 0; index < 300; index++ {
+		set.Add(NewURLPattern(TYPE_REGEXP, fmt.Sprintf(`/item-%d$`, index)))
+	}
+	if !set.Validate("https://test.org/item-299") || set.Validate("https://test.org/item-300") {
+		t.Error("Set with many regexps does not match added patterns")
+	}
+	if len(set.include.fallback) != 1 {
+		t.Errorf("Set matches %d patterns one by one, expected only invalid one", len(set.include.fallback))
+	}
+	if set.Len() != len(patterns)+300 {
+		t.Errorf("Set has %d patterns, expected %d", set.Len(), len(patterns)+300)
+	}
+}
@@ -0,0 +1,272 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+const (
+	DROP_REASON_FILTERED = "filtered"
+	DROP_REASON_INVALID  = "invalid"
+	DROP_REASON_ERROR    = "error"
+)
+
+type StageFunc func(item SaveableItem) ([]SaveableItem, error)
+
+type DropError struct {
+	Reason string
+}
+
+func (e DropError) Error() string {
+	return fmt.Sprintf("Item dropped: %s", e.Reason)
+}
+
+func Drop(reason string) error {
+	return DropError{Reason: reason}
+}
+
+func Keep(item SaveableItem) ([]SaveableItem, error) {
+	return []SaveableItem{item}, nil
+}
+
+type PipelineStageConfig struct {
+	Name     string
+	Stage    string `required:"true"`
+	Scrapers []string
+	Options  ComponentOptions
+}
+
+type StageStats struct {
+	Processed   int            `json:"processed"`
+	Emitted     int            `json:"emitted"`
+	Dropped     int            `json:"dropped"`
+	Errors      int            `json:"errors"`
+	DropReasons map[string]int `json:"dropReasons"`
+}
+
+func NewStageStats() *StageStats {
+	return &StageStats{DropReasons: make(map[string]int)}
+}
+
+type PipelineStage struct {
+	Name     string
+	Process  StageFunc
+	scrapers map[string]bool
+	closers  []io.Closer
+}
+
+func (stage *PipelineStage) ForScrapers(names ...string) *PipelineStage {
+	for _, name := range names {
+		stage.scrapers[name] = true
+	}
+	return stage
+}
+
+func (stage *PipelineStage) CloseWith(closers ...io.Closer) *PipelineStage {
+	stage.closers = append(stage.closers, closers...)
+	return stage
+}
+
+func (stage *PipelineStage) Close() (err error) {
+	for _, closer := range stage.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	stage.closers = nil
+	return
+}
+
+func (stage *PipelineStage) Applies(item SaveableItem) bool {
+	if len(stage.scrapers) == 0 {
+		return true
+	}
+	scraper := item.Scraper()
+	return scraper != nil && stage.scrapers[scraper.Name]
+}
+
+func (stage *PipelineStage) String() string {
+	return fmt.Sprintf("<PipelineStage: %s>", stage.Name)
+}
+
+func NewPipelineStage(name string, process StageFunc) (stage *PipelineStage) {
+	stage = &PipelineStage{
+		Name:     name,
+		Process:  process,
+		scrapers: make(map[string]bool),
+	}
+	return
+}
+
+type Pipeline struct {
+	stages []*PipelineStage
+}
+
+func (pipeline *Pipeline) AddStages(stages ...*PipelineStage) *Pipeline {
+	pipeline.stages = append(pipeline.stages, stages...)
+	return pipeline
+}
+
+func (pipeline *Pipeline) Stages() []*PipelineStage {
+	return pipeline.stages
+}
+
+func (pipeline *Pipeline) runStage(stage *PipelineStage, item SaveableItem, meta *EngineMeta) (result []SaveableItem) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger().Warningf("Stage %s panicked: %v", stage.Name, r)
+			meta.UpdateStageStats(item.Scraper(), stage.Name, 0, DROP_REASON_ERROR)
+			result = nil
+		}
+	}()
+
+	result, err := stage.Process(item)
+	reason := ""
+	if err != nil {
+		if drop, ok := err.(DropError); ok {
+			reason = drop.Reason
+		} else {
+			Logger().Warningf("Stage %s failed: %s", stage.Name, err)
+			reason = DROP_REASON_ERROR
+		}
+		result = nil
+	} else if len(result) == 0 {
+		reason = DROP_REASON_FILTERED
+	}
+
+	meta.UpdateStageStats(item.Scraper(), stage.Name, len(result), reason)
+	return
+}
+
+func (pipeline *Pipeline) Process(item SaveableItem, meta *EngineMeta) []SaveableItem {
+	items := []SaveableItem{item}
+
+	for _, stage := range pipeline.stages {
+		var next []SaveableItem
+		for _, current := range items {
+			if !stage.Applies(current) {
+				next = append(next, current)
+				continue
+			}
+			next = append(next, pipeline.runStage(stage, current, meta)...)
+		}
+		items = next
+		if len(items) == 0 {
+			break
+		}
+	}
+	return items
+}
+
+func (pipeline *Pipeline) Close() (err error) {
+	for _, stage := range pipeline.stages {
+		if closeErr := stage.Close(); closeErr != nil {
+			Logger().Warningf("Cannot close stage %s: %s", stage.Name, closeErr)
+			if err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return
+}
+
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+func NewPipelineStageFromConfig(registry *Registry, config PipelineStageConfig) (stage *PipelineStage, err error) {
+	process, closer, err := registry.Stage(config.Stage, config.Options)
+	if err != nil {
+		return
+	}
+
+	name := config.Name
+	if name == "" {
+		name = config.Stage
+	}
+	stage = NewPipelineStage(name, process).ForScrapers(config.Scrapers...)
+	if closer != nil {
+		stage.CloseWith(closer)
+	}
+	return
+}
+
+func ValidateStage(item SaveableItem) ([]SaveableItem, error) {
+	if !item.Validate() {
+		return nil, Drop(DROP_REASON_INVALID)
+	}
+	return Keep(item)
+}
+
+func SetFieldsStage(fields map[string]interface{}) StageFunc {
+	return func(item SaveableItem) ([]SaveableItem, error) {
+		if generic, ok := item.(GenericItem); ok {
+			for name, value := range fields {
+				generic.Fields[name] = value
+			}
+		}
+		return Keep(item)
+	}
+}
+
+func MetadataStage(item SaveableItem) ([]SaveableItem, error) {
+	if generic, ok := item.(GenericItem); ok {
+		if scraper := generic.Scraper(); scraper != nil {
+			generic.Fields["_scraper"] = scraper.Name
+		}
+		generic.Fields["_url"] = generic.Proxy.Url
+		generic.Fields["_scrapedAt"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	return Keep(item)
+}
+
+func FilterStage(field string, expression string, exclude bool) (StageFunc, error) {
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return func(item SaveableItem) ([]SaveableItem, error) {
+		generic, ok := item.(GenericItem)
+		if !ok {
+			return Keep(item)
+		}
+		matches := compiled.MatchString(fmt.Sprint(generic.Fields[field]))
+		if matches == exclude {
+			return nil, Drop(DROP_REASON_FILTERED)
+		}
+		return Keep(item)
+	}, nil
+}
+
+func init() {
+	RegisterStage("validate", func(options ComponentOptions) (StageFunc, error) {
+		return ValidateStage, nil
+	})
+	RegisterStage("metadata", func(options ComponentOptions) (StageFunc, error) {
+		return MetadataStage, nil
+	})
+	RegisterStage("set", func(options ComponentOptions) (StageFunc, error) {
+		config := struct{ Fields map[string]interface{} }{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return SetFieldsStage(config.Fields), nil
+	})
+	RegisterStage("filter", func(options ComponentOptions) (StageFunc, error) {
+		config := struct {
+			Field   string
+			Pattern string
+			Exclude bool
+		}{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		if config.Field == "" {
+			return nil, errors.New("Filter stage requires field")
+		}
+		return FilterStage(config.Field, config.Pattern, config.Exclude)
+	})
+}
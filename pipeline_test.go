@@ -0,0 +1,202 @@
+package gotana
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func pipelineItem(scraper *Scraper, title string) GenericItem {
+	item := NewGenericItem("post", ScrapedItem{Url: "http://example.com/1", scraper: scraper})
+	item.Fields["title"] = title
+	return item
+}
+
+func TestBuiltinStages(t *testing.T) {
+	filter, err := FilterStage("title", "(?i)go", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := FilterStage("title", "(?i)go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		stage    StageFunc
+		title    string
+		expected error
+		fields   map[string]interface{}
+	}{
+		{"filter match", filter, "Go rocks", nil, map[string]interface{}{"title": "Go rocks"}},
+		{"filter miss", filter, "Rust", Drop(DROP_REASON_FILTERED), nil},
+		{"exclude match", exclude, "Go rocks", Drop(DROP_REASON_FILTERED), nil},
+		{"exclude miss", exclude, "Rust", nil, map[string]interface{}{"title": "Rust"}},
+		{"set", SetFieldsStage(map[string]interface{}{"source": "blog", "title": "x"}), "Go",
+			nil, map[string]interface{}{"title": "x", "source": "blog"}},
+		{"validate", ValidateStage, "Go", nil, map[string]interface{}{"title": "Go"}},
+	}
+
+	for _, test := range tests {
+		items, err := test.stage(pipelineItem(nil, test.title))
+		if err != test.expected {
+			t.Errorf("%s: stage returned error %v, expected %v", test.name, err, test.expected)
+			continue
+		}
+		if test.fields == nil {
+			if len(items) != 0 {
+				t.Errorf("%s: stage returned %v, expected none", test.name, items)
+			}
+			continue
+		}
+		if len(items) != 1 || !reflect.DeepEqual(items[0].(GenericItem).Fields, test.fields) {
+			t.Errorf("%s: stage returned %v, expected %v", test.name, items, test.fields)
+		}
+	}
+
+	if _, err := FilterStage("title", "(", false); err == nil {
+		t.Error("FilterStage accepted invalid pattern")
+	}
+	if items, _ := ValidateStage(FeedEntry{Title: "no link"}); len(items) != 0 {
+		t.Error("ValidateStage kept invalid item")
+	}
+}
+
+func TestMetadataStage(t *testing.T) {
+	scraper := NewScraper(ScraperParams{Name: "example", Url: "http://example.com"})
+	items, err := MetadataStage(pipelineItem(scraper, "Go"))
+	if err != nil || len(items) != 1 {
+		t.Fatalf("MetadataStage returned %v, %v", items, err)
+	}
+	fields := items[0].(GenericItem).Fields
+	if fields["_scraper"] != "example" || fields["_url"] != "http://example.com/1" || fields["_scrapedAt"] == "" {
+		t.Errorf("MetadataStage set %v", fields)
+	}
+}
+
+func TestPipelineFromConfig(t *testing.T) {
+	data := `
+pipeline:
+- stage: metadata
+- stage: set
+  options:
+    fields:
+      source: blog
+- stage: filter
+  name: only-go
+  scrapers: [a]
+  options:
+    field: title
+    pattern: (?i)go
+scrapers:
+- name: a
+  url: http://a.com
+  requestlimit: 1
+- name: b
+  url: http://b.com
+  requestlimit: 1
+`
+	config := &ScraperConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine().FromConfig(config)
+	engine.AddPipelineStages(NewPipelineStage("fork", func(item SaveableItem) ([]SaveableItem, error) {
+		generic := item.(GenericItem)
+		switch generic.Fields["title"] {
+		case "boom":
+			return nil, errors.New("boom")
+		case "panic":
+			panic("panic")
+		}
+		copied := NewGenericItem("copy", generic.Proxy)
+		copied.Fields["title"] = generic.Fields["title"]
+		return []SaveableItem{item, copied}, nil
+	}))
+	a, b := engine.GetScraper("a"), engine.GetScraper("b")
+
+	tests := []struct {
+		scraper *Scraper
+		title   string
+		emitted int
+	}{
+		{a, "Go rocks", 2},
+		{a, "Rust", 0},
+		{b, "Rust", 2},
+		{b, "boom", 0},
+		{b, "panic", 0},
+	}
+	for _, test := range tests {
+		items := engine.Pipeline().Process(pipelineItem(test.scraper, test.title), engine.Meta)
+		if len(items) != test.emitted {
+			t.Errorf("Pipeline emitted %v for %q of %s, expected %d items", items, test.title, test.scraper.Name, test.emitted)
+			continue
+		}
+		for _, item := range items {
+			if fields := item.(GenericItem).Fields; item.(GenericItem).Kind == "post" && (fields["source"] != "blog" || fields["_scraper"] != test.scraper.Name) {
+				t.Errorf("Pipeline emitted %v without fields of earlier stages", fields)
+			}
+		}
+	}
+
+	statsA := engine.Meta.PipelineStats(a)
+	if stage := statsA["only-go"]; stage.Processed != 2 || stage.Dropped != 1 || stage.DropReasons[DROP_REASON_FILTERED] != 1 {
+		t.Errorf("Filter stage stats of a: %+v", stage)
+	}
+	if stage := statsA["fork"]; stage.Emitted != 2 {
+		t.Errorf("Fork stage stats of a: %+v", stage)
+	}
+	statsB := engine.Meta.PipelineStats(b)
+	if _, ok := statsB["only-go"]; ok {
+		t.Error("Filter stage limited to a processed items of b")
+	}
+	if stage := statsB["fork"]; stage.Errors != 2 || stage.DropReasons[DROP_REASON_ERROR] != 2 {
+		t.Errorf("Fork stage stats of b: %+v", stage)
+	}
+}
+
+func TestPipelineClose(t *testing.T) {
+	var closed []string
+	closer := func(name string, err error) closerFunc {
+		return func() error {
+			closed = append(closed, name)
+			return err
+		}
+	}
+	failure := errors.New("failed")
+
+	pipeline := NewPipeline().AddStages(
+		NewPipelineStage("first", Keep).CloseWith(closer("a", nil), closer("b", failure)),
+		NewPipelineStage("second", Keep).CloseWith(closer("c", nil)),
+	)
+	if err := pipeline.Close(); err != failure {
+		t.Errorf("Close returned %v, expected %v", err, failure)
+	}
+	if err := pipeline.Close(); err != nil {
+		t.Errorf("Second close returned %v", err)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(closed, expected) {
+		t.Errorf("Closed %v, expected %v", closed, expected)
+	}
+}
+
+func TestNewPipelineStageFromConfigErrors(t *testing.T) {
+	for _, config := range []PipelineStageConfig{
+		{Stage: "missing"},
+		{Stage: "filter"},
+		{Stage: "filter", Options: ComponentOptions{"field": "title", "pattern": "("}},
+	} {
+		if _, err := NewPipelineStageFromConfig(DefaultRegistry(), config); err == nil {
+			t.Errorf("NewPipelineStageFromConfig(%+v) succeeded, expected error", config)
+		}
+	}
+}
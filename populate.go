@@ -0,0 +1,351 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TAG_NAME = "gotana"
+
+var tagKeys = []string{"css", "xpath", "attr", "extract", "layout", "trim"}
+
+type fieldTag struct {
+	css     string
+	xpath   string
+	attr    string
+	extract string
+	layout  string
+	trim    bool
+}
+
+func parseFieldTag(tag string) (result fieldTag, err error) {
+	result = fieldTag{extract: EXTRACT_TEXT, layout: time.RFC3339, trim: true}
+
+	for _, chunk := range splitFieldTag(tag) {
+		pair := strings.SplitN(chunk, "=", 2)
+		key := strings.TrimSpace(pair[0])
+		value := ""
+		if len(pair) == 2 {
+			value = pair[1]
+		}
+
+		switch key {
+		case "css":
+			result.css = value
+		case "xpath":
+			result.xpath = value
+		case "attr":
+			result.attr = value
+			result.extract = EXTRACT_ATTR
+		case "extract":
+			result.extract = value
+		case "layout":
+			result.layout = value
+		case "trim":
+			result.trim = value != "false"
+		default:
+			err = errors.New(fmt.Sprintf("Unknown tag key: %s", key))
+			return
+		}
+	}
+	return
+}
+
+func splitFieldTag(tag string) (chunks []string) {
+	start := 0
+	for i := 0; i < len(tag); i++ {
+		if tag[i] != ',' {
+			continue
+		}
+		rest := tag[i+1:]
+		for _, key := range tagKeys {
+			if strings.HasPrefix(rest, key+"=") {
+				chunks = append(chunks, tag[start:i])
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start < len(tag) {
+		chunks = append(chunks, tag[start:])
+	}
+	return
+}
+
+type PopulateError struct {
+	Errors map[string]error
+}
+
+func (e *PopulateError) Add(field string, err error) {
+	if nested, ok := err.(*PopulateError); ok {
+		for name, nestedErr := range nested.Errors {
+			e.Errors[field+"."+name] = nestedErr
+		}
+		return
+	}
+	e.Errors[field] = err
+}
+
+func (e *PopulateError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for index, name := range names {
+		messages[index] = fmt.Sprintf("%s: %s", name, e.Errors[name])
+	}
+	return "Cannot populate fields. " + strings.Join(messages, "; ")
+}
+
+type tagMatch struct {
+	selection *goquery.Selection
+	node      XPathNode
+}
+
+func (match tagMatch) value(tag fieldTag) (value string, ok bool) {
+	if match.selection == nil {
+		switch tag.extract {
+		case EXTRACT_ATTR:
+			value, ok = match.node.Attr(tag.attr)
+		case EXTRACT_HTML:
+			value, ok = match.node.HTML(), true
+		default:
+			value, ok = match.node.Text(), true
+		}
+	} else {
+		switch tag.extract {
+		case EXTRACT_ATTR:
+			value, ok = match.selection.Attr(tag.attr)
+		case EXTRACT_HTML:
+			markup, err := match.selection.Html()
+			value, ok = markup, err == nil
+		default:
+			value, ok = match.selection.Text(), true
+		}
+	}
+
+	if tag.trim {
+		value = strings.TrimSpace(value)
+	}
+	return
+}
+
+func (match tagMatch) scope() *goquery.Selection {
+	if match.selection != nil {
+		return match.selection
+	}
+	return goquery.NewDocumentFromNode(match.node.HTMLNode()).Selection
+}
+
+func findTagMatches(selection *goquery.Selection, tag fieldTag) (matches []tagMatch, err error) {
+	if tag.xpath != "" {
+		if len(selection.Nodes) == 0 {
+			return
+		}
+		nodes, err := NewHTMLXPathNode(selection.Nodes[0]).Find(tag.xpath)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			matches = append(matches, tagMatch{node: node})
+		}
+		return matches, nil
+	}
+
+	if tag.css != "" {
+		selection = selection.Find(tag.css)
+	}
+	selection.Each(func(i int, s *goquery.Selection) {
+		matches = append(matches, tagMatch{selection: s})
+	})
+	return
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func convertValue(raw string, target reflect.Value, tag fieldTag) error {
+	if target.Kind() == reflect.Ptr {
+		value := reflect.New(target.Type().Elem())
+		if err := convertValue(raw, value.Elem(), tag); err != nil {
+			return err
+		}
+		target.Set(value)
+		return nil
+	}
+
+	if target.Type() == timeType {
+		parsed, err := time.Parse(tag.layout, raw)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, target.Type().Bits())
+		if err != nil {
+			return err
+		}
+		target.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		target.SetBool(parsed)
+	default:
+		return errors.New(fmt.Sprintf("Unsupported field type: %s", target.Type()))
+	}
+	return nil
+}
+
+func populateNested(scope *goquery.Selection, target reflect.Value) error {
+	if target.Kind() == reflect.Ptr {
+		value := reflect.New(target.Type().Elem())
+		if err := populateStruct(scope, value.Elem()); err != nil {
+			return err
+		}
+		target.Set(value)
+		return nil
+	}
+	return populateStruct(scope, target)
+}
+
+func populateField(selection *goquery.Selection, target reflect.Value, tag fieldTag) error {
+	matches, err := findTagMatches(selection, tag)
+	if err != nil {
+		return err
+	}
+
+	if target.Kind() == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(target.Type(), 0, len(matches))
+		populateErrors := &PopulateError{Errors: make(map[string]error)}
+
+		for index, match := range matches {
+			element := reflect.New(target.Type().Elem()).Elem()
+			if isNestedStruct(element.Type()) {
+				err = populateNested(match.scope(), element)
+			} else if raw, ok := match.value(tag); ok && raw != "" {
+				err = convertValue(raw, element, tag)
+			} else {
+				continue
+			}
+			if err != nil {
+				populateErrors.Add(strconv.Itoa(index), err)
+				continue
+			}
+			slice = reflect.Append(slice, element)
+		}
+
+		target.Set(slice)
+		if len(populateErrors.Errors) > 0 {
+			return populateErrors
+		}
+		return nil
+	}
+
+	if isNestedStruct(target.Type()) {
+		if tag.css == "" && tag.xpath == "" {
+			return populateNested(selection, target)
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+		return populateNested(matches[0].scope(), target)
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+	raw, ok := matches[0].value(tag)
+	if !ok || raw == "" {
+		return nil
+	}
+	return convertValue(raw, target, tag)
+}
+
+func populateStruct(selection *goquery.Selection, target reflect.Value) error {
+	populateErrors := &PopulateError{Errors: make(map[string]error)}
+	targetType := target.Type()
+
+	for index := 0; index < targetType.NumField(); index++ {
+		structField := targetType.Field(index)
+		rawTag, ok := structField.Tag.Lookup(TAG_NAME)
+		if !ok || rawTag == "-" || structField.PkgPath != "" {
+			continue
+		}
+
+		tag, err := parseFieldTag(rawTag)
+		if err == nil {
+			err = populateField(selection, target.Field(index), tag)
+		}
+		if err != nil {
+			populateErrors.Add(structField.Name, err)
+		}
+	}
+
+	if len(populateErrors.Errors) > 0 {
+		return populateErrors
+	}
+	return nil
+}
+
+func Populate(target interface{}, selection *goquery.Selection) error {
+	if target == nil {
+		return errors.New("Populate expects pointer to struct, got nil")
+	}
+	value := reflect.ValueOf(target)
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return errors.New(fmt.Sprintf("Populate expects pointer to struct, got nil %s", value.Type()))
+	}
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("Populate expects pointer to struct, got %s", value.Type()))
+	}
+	return populateStruct(selection, value.Elem())
+}
+
+func PopulateFromProxy(target interface{}, proxy ScrapedItem) error {
+	document, err := proxy.HTMLDocument()
+	if err != nil {
+		return err
+	}
+
+	if mixin, ok := target.(interface {
+		SetProxy(ScrapedItem) *ScraperMixin
+	}); ok {
+		mixin.SetProxy(proxy)
+	}
+	return Populate(target, document.Selection)
+}
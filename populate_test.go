@@ -0,0 +1,173 @@
+package gotana
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type testAuthor struct {
+	Name string `gotana:"css=.name"`
+}
+
+type testIssue struct {
+	ScraperMixin
+	Title    string        `gotana:"css=h3 a"`
+	Href     string        `gotana:"css=h3 a,attr=href"`
+	Votes    int           `gotana:"css=.votes"`
+	Score    float64       `gotana:"xpath=//span[@class='score']"`
+	Date     time.Time     `gotana:"css=time,attr=datetime,layout=2006-01-02"`
+	Tags     []string      `gotana:"css=.tag"`
+	Labels   []string      `gotana:"css=.tag,attr=title"`
+	Author   testAuthor    `gotana:"css=.author"`
+	Authors  []*testAuthor `gotana:"css=.author"`
+	Selector string        `gotana:"css=h1, h3 a"`
+	Note     string
+}
+
+func testSelection(t *testing.T, body string) *goquery.Selection {
+	document, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document.Selection
+}
+
+func TestPopulate(t *testing.T) {
+	const page = `<h3><a href="/x"> T </a></h3><span class="votes">12</span><span class="score">1.5</span>
+<time datetime="2020-01-02"></time><i class="tag" title="first">a</i><i class="tag" title="">b</i><i class="tag"> </i>
+<div class="author"><b class="name">Ann</b></div><div class="author"><b class="name">Bob</b></div>`
+
+	issue := testIssue{Note: "kept"}
+	if err := Populate(&issue, testSelection(t, page)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field    string
+		expected interface{}
+	}{
+		{"Title", "T"},
+		{"Href", "/x"},
+		{"Votes", 12},
+		{"Score", 1.5},
+		{"Date", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"Tags", []string{"a", "b"}},
+		{"Labels", []string{"first"}},
+		{"Author", testAuthor{Name: "Ann"}},
+		{"Authors", []*testAuthor{{Name: "Ann"}, {Name: "Bob"}}},
+		{"Selector", "T"},
+		{"Note", "kept"},
+	}
+	value := reflect.ValueOf(issue)
+	for _, test := range tests {
+		if field := value.FieldByName(test.field).Interface(); !reflect.DeepEqual(field, test.expected) {
+			t.Errorf("%s populated with %#v, expected %#v", test.field, field, test.expected)
+		}
+	}
+}
+
+func TestPopulateSkipsEmptyValues(t *testing.T) {
+	type target struct {
+		Title  string   `gotana:"css=h3"`
+		Votes  int      `gotana:"css=.votes"`
+		Rating *float64 `gotana:"css=.rating"`
+		Tags   []string `gotana:"css=.tag"`
+		Counts []int    `gotana:"css=.count"`
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		expected target
+	}{
+		{"missing", `<p></p>`, target{Tags: []string{}, Counts: []int{}}},
+		{"empty", `<h3> </h3><span class="votes"></span><span class="rating"></span><i class="tag"></i><i class="count"> </i>`,
+			target{Tags: []string{}, Counts: []int{}}},
+		{"mixed", `<h3>T</h3><i class="tag"></i><i class="tag">a</i><i class="count">1</i><i class="count"></i><i class="count">2</i>`,
+			target{Title: "T", Tags: []string{"a"}, Counts: []int{1, 2}}},
+	}
+
+	for _, test := range tests {
+		var result target
+		if err := Populate(&result, testSelection(t, test.body)); err != nil {
+			t.Errorf("%s: Populate failed: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: Populate returned %+v, expected %+v", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestPopulateErrors(t *testing.T) {
+	type target struct {
+		Title string `gotana:"css=h3"`
+		Votes int    `gotana:"css=.votes"`
+		Tags  []int  `gotana:"css=.tag"`
+	}
+
+	var result target
+	err := Populate(&result, testSelection(t, `<h3>T</h3><span class="votes">x</span><i class="tag">1</i><i class="tag">y</i>`))
+	populateErr, ok := err.(*PopulateError)
+	if !ok {
+		t.Fatalf("Populate returned %v, expected *PopulateError", err)
+	}
+	for _, field := range []string{"Votes", "Tags.1"} {
+		if populateErr.Errors[field] == nil {
+			t.Errorf("Missing error of %s in %v", field, populateErr)
+		}
+	}
+	if len(populateErr.Errors) != 2 || result.Title != "T" || !reflect.DeepEqual(result.Tags, []int{1}) {
+		t.Errorf("Populate returned %v and %+v", populateErr, result)
+	}
+
+	var unknown struct {
+		Title string `gotana:"size=1"`
+	}
+	if err := Populate(&unknown, testSelection(t, `<h3>T</h3>`)); err == nil {
+		t.Error("Populate accepted unknown tag key")
+	}
+}
+
+func TestPopulateRejectsInvalidTargets(t *testing.T) {
+	type target struct {
+		Title string `gotana:"css=p"`
+	}
+	var nilTarget *target
+	var text string
+
+	selection := testSelection(t, `<p>x</p>`)
+	for _, invalid := range []interface{}{nil, nilTarget, target{}, &text, 5} {
+		if err := Populate(invalid, selection); err == nil {
+			t.Errorf("Populate(%#v) succeeded, expected error", invalid)
+		}
+	}
+	if err := PopulateFromProxy(nil, ScrapedItem{BodyBytes: []byte(`<p>x</p>`)}); err == nil {
+		t.Error("PopulateFromProxy(nil) succeeded, expected error")
+	}
+
+	var valid target
+	if err := Populate(&valid, selection); err != nil || valid.Title != "x" {
+		t.Errorf("Populate returned %v and %+v", err, valid)
+	}
+}
+
+func TestSplitFieldTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected []string
+	}{
+		{"css=a", []string{"css=a"}},
+		{"css=h1, h2,attr=href", []string{"css=h1, h2", "attr=href"}},
+		{"xpath=//a[@x='1,2'],trim=false", []string{"xpath=//a[@x='1,2']", "trim=false"}},
+	}
+	for _, test := range tests {
+		if chunks := splitFieldTag(test.tag); !reflect.DeepEqual(chunks, test.expected) {
+			t.Errorf("splitFieldTag(%q) returned %q, expected %q", test.tag, chunks, test.expected)
+		}
+	}
+}
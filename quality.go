@@ -0,0 +1,374 @@
+package gotana
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	QUALITY_TOP_VALUES      = 10
+	QUALITY_TRACKED_VALUES  = 1000
+	QUALITY_MAX_VALUE_CHARS = 100
+)
+
+type QualityThresholds struct {
+	MinItems      int
+	MaxErrorRatio *float64
+	Coverage      map[string]float64
+}
+
+type QualityConfig struct {
+	Report     string
+	Thresholds QualityThresholds
+	Scrapers   map[string]QualityThresholds
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type FieldQuality struct {
+	Count     int          `json:"count"`
+	Coverage  float64      `json:"coverage"`
+	TopValues []ValueCount `json:"topValues"`
+	Min       *float64     `json:"min,omitempty"`
+	Max       *float64     `json:"max,omitempty"`
+	Mean      *float64     `json:"mean,omitempty"`
+}
+
+type QualityCheck struct {
+	Name     string  `json:"name"`
+	Passed   bool    `json:"passed"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+}
+
+type QualityReport struct {
+	Scraper          string                  `json:"scraper"`
+	Started          time.Time               `json:"started"`
+	Finished         time.Time               `json:"finished"`
+	Items            int                     `json:"items"`
+	Requests         int                     `json:"requests"`
+	Failed           int                     `json:"failed"`
+	ErrorRatio       float64                 `json:"errorRatio"`
+	ValidationErrors map[string]int          `json:"validationErrors"`
+	Fields           map[string]FieldQuality `json:"fields"`
+	Checks           []QualityCheck          `json:"checks"`
+	Passed           bool                    `json:"passed"`
+}
+
+func (report QualityReport) String() string {
+	status := "PASSED"
+	if !report.Passed {
+		status = "FAILED"
+	}
+	return fmt.Sprintf("<QualityReport: %s>. %s. Items: %d, error ratio: %.2f",
+		report.Scraper, status, report.Items, report.ErrorRatio)
+}
+
+type QualityReportListener interface {
+	QualityReport(scraper *Scraper, report QualityReport)
+}
+
+type QualityReporter interface {
+	QualityReports() map[string]QualityReport
+}
+
+type fieldTracker struct {
+	count  int
+	values map[string]int
+	sum    float64
+	number int
+	min    float64
+	max    float64
+}
+
+func (tracker *fieldTracker) add(value interface{}) {
+	tracker.count += 1
+
+	if number, ok := value.(float64); ok {
+		if tracker.number == 0 || number < tracker.min {
+			tracker.min = number
+		}
+		if tracker.number == 0 || number > tracker.max {
+			tracker.max = number
+		}
+		tracker.sum += number
+		tracker.number += 1
+	}
+
+	var key string
+	switch v := value.(type) {
+	case string:
+		key = v
+	default:
+		encoded, _ := json.Marshal(v)
+		key = string(encoded)
+	}
+	if len(key) > QUALITY_MAX_VALUE_CHARS {
+		key = key[:QUALITY_MAX_VALUE_CHARS]
+	}
+	if _, ok := tracker.values[key]; ok || len(tracker.values) < QUALITY_TRACKED_VALUES {
+		tracker.values[key] += 1
+	}
+}
+
+func (tracker *fieldTracker) quality(items int) (quality FieldQuality) {
+	quality.Count = tracker.count
+	if items > 0 {
+		quality.Coverage = float64(tracker.count) / float64(items)
+	}
+
+	for value, count := range tracker.values {
+		quality.TopValues = append(quality.TopValues, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(quality.TopValues, func(i, j int) bool {
+		if quality.TopValues[i].Count == quality.TopValues[j].Count {
+			return quality.TopValues[i].Value < quality.TopValues[j].Value
+		}
+		return quality.TopValues[i].Count > quality.TopValues[j].Count
+	})
+	if len(quality.TopValues) > QUALITY_TOP_VALUES {
+		quality.TopValues = quality.TopValues[:QUALITY_TOP_VALUES]
+	}
+
+	if tracker.number > 0 {
+		lowest, highest, mean := tracker.min, tracker.max, tracker.sum/float64(tracker.number)
+		quality.Min, quality.Max, quality.Mean = &lowest, &highest, &mean
+	}
+	return
+}
+
+type qualityRun struct {
+	started time.Time
+	items   int
+	fields  map[string]*fieldTracker
+}
+
+func (run *qualityRun) add(record map[string]interface{}) {
+	run.items += 1
+	for field, value := range record {
+		tracker, ok := run.fields[field]
+		if !ok {
+			tracker = &fieldTracker{values: make(map[string]int)}
+			run.fields[field] = tracker
+		}
+		if !isEmptyField(value) {
+			tracker.add(value)
+		}
+	}
+}
+
+func (run *qualityRun) coverage() map[string]float64 {
+	result := make(map[string]float64, len(run.fields))
+	for field, tracker := range run.fields {
+		if run.items > 0 {
+			result[field] = float64(tracker.count) / float64(run.items)
+		}
+	}
+	return result
+}
+
+func itemRecord(item SaveableItem) map[string]interface{} {
+	record := map[string]interface{}{}
+	if data, err := item.RecordData(); err == nil {
+		json.Unmarshal(data, &record)
+	}
+	return record
+}
+
+func newQualityRun() *qualityRun {
+	return &qualityRun{started: time.Now(), fields: make(map[string]*fieldTracker)}
+}
+
+type QualityMonitor struct {
+	Config  QualityConfig
+	mutex   sync.Mutex
+	runs    map[string]*qualityRun
+	reports map[string]QualityReport
+}
+
+func (monitor *QualityMonitor) run(scraper *Scraper) *qualityRun {
+	run, ok := monitor.runs[scraper.Name]
+	if !ok {
+		run = newQualityRun()
+		monitor.runs[scraper.Name] = run
+	}
+	return run
+}
+
+func (monitor *QualityMonitor) ScraperStarted(scraper *Scraper) {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.run(scraper)
+}
+
+func (monitor *QualityMonitor) ItemScraped(scraper *Scraper, item SaveableItem) {
+	if scraper == nil {
+		return
+	}
+
+	record := itemRecord(item)
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	if run, ok := monitor.runs[scraper.Name]; ok {
+		run.add(record)
+	}
+}
+
+func (monitor *QualityMonitor) thresholds(scraper string) QualityThresholds {
+	if thresholds, ok := monitor.Config.Scrapers[scraper]; ok {
+		return thresholds
+	}
+	return monitor.Config.Thresholds
+}
+
+func (monitor *QualityMonitor) Evaluate(scraper *Scraper) (report QualityReport) {
+	monitor.mutex.Lock()
+	run, ok := monitor.runs[scraper.Name]
+	if !ok {
+		run = newQualityRun()
+	}
+	report = QualityReport{
+		Scraper:  scraper.Name,
+		Started:  run.started,
+		Finished: time.Now(),
+		Items:    run.items,
+		Fields:   make(map[string]FieldQuality, len(run.fields)),
+		Passed:   true,
+	}
+	for field, tracker := range run.fields {
+		report.Fields[field] = tracker.quality(run.items)
+	}
+	monitor.mutex.Unlock()
+
+	if scraper.engine != nil {
+		crawled, _, failed := scraper.engine.Meta.RequestStats(scraper)
+		report.Requests, report.Failed = crawled, failed
+		report.ValidationErrors = scraper.engine.Meta.ValidationErrorCounts(scraper)
+	}
+	if report.Requests > 0 {
+		report.ErrorRatio = float64(report.Failed) / float64(report.Requests)
+	}
+
+	check := func(name string, passed bool, expected float64, actual float64) {
+		report.Checks = append(report.Checks, QualityCheck{Name: name, Passed: passed, Expected: expected, Actual: actual})
+		report.Passed = report.Passed && passed
+	}
+
+	thresholds := monitor.thresholds(scraper.Name)
+	if thresholds.MinItems > 0 {
+		check("minitems", report.Items >= thresholds.MinItems, float64(thresholds.MinItems), float64(report.Items))
+	}
+	if thresholds.MaxErrorRatio != nil {
+		check("maxerrorratio", report.ErrorRatio <= *thresholds.MaxErrorRatio, *thresholds.MaxErrorRatio, report.ErrorRatio)
+	}
+
+	fields := make([]string, 0, len(thresholds.Coverage))
+	for field := range thresholds.Coverage {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		coverage := report.Fields[field].Coverage
+		check("coverage."+field, coverage >= thresholds.Coverage[field], thresholds.Coverage[field], coverage)
+	}
+	return
+}
+
+func (monitor *QualityMonitor) ScraperStopped(scraper *Scraper) {
+	report := monitor.Evaluate(scraper)
+
+	monitor.mutex.Lock()
+	monitor.reports[scraper.Name] = report
+	delete(monitor.runs, scraper.Name)
+	monitor.mutex.Unlock()
+
+	if report.Passed {
+		Logger().Infof("%s", report)
+	} else {
+		Logger().Warningf("%s", report)
+	}
+
+	if monitor.Config.Report != "" {
+		if err := WriteQualityReport(ExpandReportPath(monitor.Config.Report, scraper), report); err != nil {
+			Logger().Warningf("Cannot write quality report: %s", err)
+		}
+	}
+
+	if scraper.engine != nil {
+		for _, extension := range scraper.engine.extensions {
+			if listener, ok := extension.(QualityReportListener); ok {
+				listener.QualityReport(scraper, report)
+			}
+		}
+	}
+}
+
+func (monitor *QualityMonitor) QualityReports() map[string]QualityReport {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	result := make(map[string]QualityReport, len(monitor.reports))
+	for name, report := range monitor.reports {
+		result[name] = report
+	}
+	return result
+}
+
+func NewQualityMonitor(config QualityConfig) (monitor *QualityMonitor) {
+	monitor = &QualityMonitor{
+		Config:  config,
+		runs:    make(map[string]*qualityRun),
+		reports: make(map[string]QualityReport),
+	}
+	return
+}
+
+func ExpandReportPath(path string, scraper *Scraper) string {
+	project := ""
+	if scraper.engine != nil && scraper.engine.Config != nil {
+		project = scraper.engine.Config.Project
+	}
+	return ExpandPath(path, project, scraper.Name)
+}
+
+func ExpandPath(path string, project string, scraper string) string {
+	replacer := strings.NewReplacer(
+		"{project}", project,
+		"{scraper}", scraper,
+		"{timestamp}", time.Now().UTC().Format("20060102T150405Z"),
+	)
+	return replacer.Replace(path)
+}
+
+func WriteQualityReport(path string, report QualityReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func init() {
+	RegisterExtension("quality", func(options ComponentOptions) (Extension, error) {
+		config := QualityConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewQualityMonitor(config), nil
+	})
+}
@@ -0,0 +1,170 @@
+package gotana
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type qualityRecorder struct {
+	reports []QualityReport
+}
+
+func (recorder *qualityRecorder) ScraperStarted(scraper *Scraper)                 {}
+func (recorder *qualityRecorder) ScraperStopped(scraper *Scraper)                 {}
+func (recorder *qualityRecorder) ItemScraped(scraper *Scraper, item SaveableItem) {}
+func (recorder *qualityRecorder) QualityReport(scraper *Scraper, report QualityReport) {
+	recorder.reports = append(recorder.reports, report)
+}
+
+func TestFieldTrackerQuality(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []interface{}
+		items    int
+		coverage float64
+		top      []ValueCount
+		min, max float64
+	}{
+		{"strings", []interface{}{"b", "a", "b"}, 4, 0.75, []ValueCount{{"b", 2}, {"a", 1}}, 0, 0},
+		{"numbers", []interface{}{3.0, 1.0, 2.0}, 3, 1, []ValueCount{{"1", 1}, {"2", 1}, {"3", 1}}, 1, 3},
+		{"long value", []interface{}{strings.Repeat("x", QUALITY_MAX_VALUE_CHARS+5)}, 1, 1,
+			[]ValueCount{{strings.Repeat("x", QUALITY_MAX_VALUE_CHARS), 1}}, 0, 0},
+		{"no items", nil, 0, 0, nil, 0, 0},
+	}
+
+	for _, test := range tests {
+		tracker := &fieldTracker{values: make(map[string]int)}
+		for _, value := range test.values {
+			tracker.add(value)
+		}
+		quality := tracker.quality(test.items)
+		if quality.Coverage != test.coverage || !reflect.DeepEqual(quality.TopValues, test.top) {
+			t.Errorf("%s: quality returned %+v, expected coverage %v and top values %v", test.name, quality, test.coverage, test.top)
+		}
+		if test.max == 0 {
+			if quality.Min != nil || quality.Max != nil {
+				t.Errorf("%s: quality returned numeric range for non numeric values", test.name)
+			}
+		} else if *quality.Min != test.min || *quality.Max != test.max {
+			t.Errorf("%s: quality returned range %v-%v, expected %v-%v", test.name, *quality.Min, *quality.Max, test.min, test.max)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"report.json", "report.json"},
+		{"{project}/{scraper}.json", "proj/a.json"},
+		{"{scraper}-{scraper}", "a-a"},
+	}
+	for _, test := range tests {
+		if path := ExpandPath(test.path, "proj", "a"); path != test.expected {
+			t.Errorf("ExpandPath(%q) returned %s, expected %s", test.path, path, test.expected)
+		}
+	}
+	if path := ExpandPath("{timestamp}", "", ""); len(path) != len("20060102T150405Z") {
+		t.Errorf("ExpandPath of timestamp returned %s", path)
+	}
+}
+
+func TestQualityMonitor(t *testing.T) {
+	dir := t.TempDir()
+	data := `
+project: proj
+extensions:
+- name: quality
+  options:
+    report: ` + dir + `/{project}/{scraper}.json
+    thresholds:
+      minitems: 3
+      maxerrorratio: 0.5
+      coverage:
+        title: 0.9
+    scrapers:
+      b:
+        minitems: 10
+scrapers:
+- name: a
+  url: http://a.com
+  requestlimit: 1
+- name: b
+  url: http://b.com
+  requestlimit: 1
+`
+	config := &ScraperConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	recorder := &qualityRecorder{}
+	engine := NewEngine().FromConfig(config).UseExtension(recorder)
+	monitor := engine.extensions[0].(*QualityMonitor)
+	a, b := engine.GetScraper("a"), engine.GetScraper("b")
+
+	for _, scraper := range []*Scraper{a, b} {
+		monitor.ScraperStarted(scraper)
+		for index, title := range []string{"A", "", "A", "B"} {
+			item := NewGenericItem("post", ScrapedItem{scraper: scraper})
+			item.Fields["title"] = title
+			item.Fields["price"] = float64(index)
+			monitor.ItemScraped(scraper, item)
+		}
+	}
+	engine.Meta.UpdateRequestStats(a, true, nil, nil)
+	engine.Meta.UpdateRequestStats(a, false, nil, nil)
+	monitor.ScraperStopped(a)
+	monitor.ScraperStopped(b)
+
+	tests := []struct {
+		scraper    string
+		errorRatio float64
+		checks     []QualityCheck
+	}{
+		{"a", 0.5, []QualityCheck{
+			{Name: "minitems", Passed: true, Expected: 3, Actual: 4},
+			{Name: "maxerrorratio", Passed: true, Expected: 0.5, Actual: 0.5},
+			{Name: "coverage.title", Passed: false, Expected: 0.9, Actual: 0.75},
+		}},
+		{"b", 0, []QualityCheck{
+			{Name: "minitems", Passed: false, Expected: 10, Actual: 4},
+		}},
+	}
+
+	reports := monitor.QualityReports()
+	for _, test := range tests {
+		report := reports[test.scraper]
+		if report.Items != 4 || report.Passed || report.ErrorRatio != test.errorRatio {
+			t.Errorf("Report of %s is %s, expected 4 failed items with error ratio %v", test.scraper, report, test.errorRatio)
+		}
+		if title := report.Fields["title"]; title.Coverage != 0.75 || title.TopValues[0] != (ValueCount{"A", 2}) {
+			t.Errorf("Title quality of %s is %+v", test.scraper, title)
+		}
+		if price := report.Fields["price"]; *price.Min != 0 || *price.Max != 3 || *price.Mean != 1.5 {
+			t.Errorf("Price quality of %s is %v-%v", test.scraper, *price.Min, *price.Max)
+		}
+		if !reflect.DeepEqual(report.Checks, test.checks) {
+			t.Errorf("Checks of %s are %+v, expected %+v", test.scraper, report.Checks, test.checks)
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, "proj", test.scraper+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := QualityReport{}
+		if err := json.Unmarshal(content, &decoded); err != nil || decoded.Items != 4 || decoded.Scraper != test.scraper {
+			t.Errorf("Report file of %s contains %s: %v", test.scraper, content, err)
+		}
+	}
+
+	if len(recorder.reports) != 2 {
+		t.Errorf("Listener received %d reports, expected 2", len(recorder.reports))
+	}
+}
@@ -0,0 +1,364 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+	yaml "gopkg.in/yaml.v2"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	COMPONENT_EXTRACTOR           = "extractor"
+	COMPONENT_MIDDLEWARE          = "middleware"
+	COMPONENT_RESPONSE_MIDDLEWARE = "responsemiddleware"
+	COMPONENT_EXTENSION           = "extension"
+	COMPONENT_HANDLER             = "handler"
+	COMPONENT_STAGE               = "stage"
+)
+
+var componentKinds = []string{
+	COMPONENT_EXTRACTOR,
+	COMPONENT_MIDDLEWARE,
+	COMPONENT_RESPONSE_MIDDLEWARE,
+	COMPONENT_EXTENSION,
+	COMPONENT_HANDLER,
+	COMPONENT_STAGE,
+}
+
+type ComponentOptions map[string]interface{}
+
+func (options ComponentOptions) Decode(target interface{}) error {
+	data, err := yaml.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, target)
+}
+
+func NewComponentOptions(config interface{}) (options ComponentOptions) {
+	options = ComponentOptions{}
+	data, err := yaml.Marshal(config)
+	if err == nil {
+		yaml.Unmarshal(data, &options)
+	}
+	return
+}
+
+type ComponentConfig struct {
+	Name    string `required:"true"`
+	Options ComponentOptions
+}
+
+type ExtractorFactory func(options ComponentOptions) (Extractable, error)
+
+type MiddlewareFactory func(options ComponentOptions) (RequestMiddlewareFunc, error)
+
+type ResponseMiddlewareFactory func(options ComponentOptions) (ResponseMiddlewareFunc, error)
+
+type ExtensionFactory func(options ComponentOptions) (Extension, error)
+
+type HandlerFactory func(options ComponentOptions) (ScrapingHandlerFunc, error)
+
+type StageFactory func(options ComponentOptions) (StageFunc, error)
+
+type ClosingStageFactory func(options ComponentOptions) (StageFunc, io.Closer, error)
+
+type Registry struct {
+	mutex              sync.RWMutex
+	extractors         map[string]ExtractorFactory
+	middleware         map[string]MiddlewareFactory
+	responseMiddleware map[string]ResponseMiddlewareFactory
+	extensions         map[string]ExtensionFactory
+	handlers           map[string]HandlerFactory
+	stages             map[string]ClosingStageFactory
+}
+
+func (registry *Registry) RegisterExtractor(name string, factory ExtractorFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.extractors[name] = factory
+	return registry
+}
+
+func (registry *Registry) RegisterMiddleware(name string, factory MiddlewareFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.middleware[name] = factory
+	return registry
+}
+
+func (registry *Registry) RegisterResponseMiddleware(name string, factory ResponseMiddlewareFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.responseMiddleware[name] = factory
+	return registry
+}
+
+func (registry *Registry) RegisterExtension(name string, factory ExtensionFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.extensions[name] = factory
+	return registry
+}
+
+func (registry *Registry) RegisterHandler(name string, factory HandlerFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.handlers[name] = factory
+	return registry
+}
+
+func (registry *Registry) RegisterStage(name string, factory StageFactory) *Registry {
+	return registry.RegisterClosingStage(name, func(options ComponentOptions) (StageFunc, io.Closer, error) {
+		process, err := factory(options)
+		return process, nil, err
+	})
+}
+
+func (registry *Registry) RegisterClosingStage(name string, factory ClosingStageFactory) *Registry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.stages[name] = factory
+	return registry
+}
+
+func notRegistered(kind string, name string) error {
+	return errors.New(fmt.Sprintf("No %s registered under name: %s", kind, name))
+}
+
+func (registry *Registry) Extractor(name string, options ComponentOptions) (Extractable, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.extractors[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, notRegistered(COMPONENT_EXTRACTOR, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) Middleware(name string, options ComponentOptions) (RequestMiddlewareFunc, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.middleware[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, notRegistered(COMPONENT_MIDDLEWARE, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) ResponseMiddleware(name string, options ComponentOptions) (ResponseMiddlewareFunc, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.responseMiddleware[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, notRegistered(COMPONENT_RESPONSE_MIDDLEWARE, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) Extension(name string, options ComponentOptions) (Extension, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.extensions[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, notRegistered(COMPONENT_EXTENSION, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) Handler(name string, options ComponentOptions) (ScrapingHandlerFunc, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.handlers[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, notRegistered(COMPONENT_HANDLER, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) Stage(name string, options ComponentOptions) (StageFunc, io.Closer, error) {
+	registry.mutex.RLock()
+	factory, ok := registry.stages[name]
+	registry.mutex.RUnlock()
+	if !ok {
+		return nil, nil, notRegistered(COMPONENT_STAGE, name)
+	}
+	return factory(options)
+}
+
+func (registry *Registry) Available(kind string) (names []string) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	switch kind {
+	case COMPONENT_EXTRACTOR:
+		for name := range registry.extractors {
+			names = append(names, name)
+		}
+	case COMPONENT_MIDDLEWARE:
+		for name := range registry.middleware {
+			names = append(names, name)
+		}
+	case COMPONENT_RESPONSE_MIDDLEWARE:
+		for name := range registry.responseMiddleware {
+			names = append(names, name)
+		}
+	case COMPONENT_EXTENSION:
+		for name := range registry.extensions {
+			names = append(names, name)
+		}
+	case COMPONENT_HANDLER:
+		for name := range registry.handlers {
+			names = append(names, name)
+		}
+	case COMPONENT_STAGE:
+		for name := range registry.stages {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+func NewRegistry() (registry *Registry) {
+	registry = &Registry{
+		extractors:         make(map[string]ExtractorFactory),
+		middleware:         make(map[string]MiddlewareFactory),
+		responseMiddleware: make(map[string]ResponseMiddlewareFactory),
+		extensions:         make(map[string]ExtensionFactory),
+		handlers:           make(map[string]HandlerFactory),
+		stages:             make(map[string]ClosingStageFactory),
+	}
+	return
+}
+
+var defaultRegistry = NewRegistry()
+
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+func RegisterExtractor(name string, factory ExtractorFactory) {
+	defaultRegistry.RegisterExtractor(name, factory)
+}
+
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	defaultRegistry.RegisterMiddleware(name, factory)
+}
+
+func RegisterResponseMiddleware(name string, factory ResponseMiddlewareFactory) {
+	defaultRegistry.RegisterResponseMiddleware(name, factory)
+}
+
+func RegisterExtension(name string, factory ExtensionFactory) {
+	defaultRegistry.RegisterExtension(name, factory)
+}
+
+func RegisterHandler(name string, factory HandlerFactory) {
+	defaultRegistry.RegisterHandler(name, factory)
+}
+
+func RegisterStage(name string, factory StageFactory) {
+	defaultRegistry.RegisterStage(name, factory)
+}
+
+func RegisterClosingStage(name string, factory ClosingStageFactory) {
+	defaultRegistry.RegisterClosingStage(name, factory)
+}
+
+func StaticMiddleware(middleware RequestMiddlewareFunc) MiddlewareFactory {
+	return func(options ComponentOptions) (RequestMiddlewareFunc, error) {
+		return middleware, nil
+	}
+}
+
+func StaticHandler(handler ScrapingHandlerFunc) HandlerFactory {
+	return func(options ComponentOptions) (ScrapingHandlerFunc, error) {
+		return handler, nil
+	}
+}
+
+type ActiveComponents struct {
+	mutex sync.Mutex
+	names map[string][]string
+}
+
+func (active *ActiveComponents) Add(kind string, name string) {
+	active.mutex.Lock()
+	defer active.mutex.Unlock()
+	for _, existing := range active.names[kind] {
+		if existing == name {
+			return
+		}
+	}
+	active.names[kind] = append(active.names[kind], name)
+}
+
+func (active *ActiveComponents) Names(kind string) (names []string) {
+	active.mutex.Lock()
+	defer active.mutex.Unlock()
+	names = append(names, active.names[kind]...)
+	sort.Strings(names)
+	return
+}
+
+func NewActiveComponents() *ActiveComponents {
+	return &ActiveComponents{names: make(map[string][]string)}
+}
+
+func DescribeComponents(registry *Registry, active *ActiveComponents) map[string]map[string][]string {
+	result := make(map[string]map[string][]string)
+	for _, kind := range componentKinds {
+		result[kind] = map[string][]string{
+			"available": registry.Available(kind),
+			"active":    active.Names(kind),
+		}
+	}
+	return result
+}
+
+func init() {
+	RegisterExtractor("link", func(options ComponentOptions) (Extractable, error) {
+		config := LinkExtractorConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewLinkExtractor(config)
+	})
+	RegisterExtractor("json", func(options ComponentOptions) (Extractable, error) {
+		config := struct{ Paths []string }{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewJSONExtractor(config.Paths...)
+	})
+	RegisterExtractor("sitemap", func(options ComponentOptions) (Extractable, error) {
+		config := SitemapConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewSitemapExtractor(config)
+	})
+	RegisterExtractor("feed", func(options ComponentOptions) (Extractable, error) {
+		config := FeedConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, err
+		}
+		return NewFeedExtractor(config), nil
+	})
+
+	RegisterMiddleware("delacceptencoding", StaticMiddleware(DelAcceptEncodingMiddleware))
+	RegisterMiddleware("randomuseragent", StaticMiddleware(RandomUserAgentMiddleware))
+
+	RegisterExtension("redis", func(options ComponentOptions) (Extension, error) {
+		return new(SaveInRedisExtension), nil
+	})
+	RegisterExtension("save", func(options ComponentOptions) (Extension, error) {
+		return new(SaveInRedisExtension), nil
+	})
+	RegisterExtension("display", func(options ComponentOptions) (Extension, error) {
+		return new(DisplayExtension), nil
+	})
+}
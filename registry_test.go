@@ -0,0 +1,171 @@
+package gotana
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestRegistryLookup(t *testing.T) {
+	registry := NewRegistry().
+		RegisterExtractor("links", func(options ComponentOptions) (Extractable, error) {
+			return NewLinkExtractor(LinkExtractorConfig{})
+		}).
+		RegisterMiddleware("noop", StaticMiddleware(func(request *http.Request) *http.Request { return request })).
+		RegisterResponseMiddleware("noop", func(options ComponentOptions) (ResponseMiddlewareFunc, error) {
+			return func(response *http.Response) *http.Response { return response }, nil
+		}).
+		RegisterExtension("display", func(options ComponentOptions) (Extension, error) {
+			return new(DisplayExtension), nil
+		}).
+		RegisterHandler("b", StaticHandler(func(proxy ScrapedItem, items chan<- SaveableItem) {})).
+		RegisterHandler("a", StaticHandler(func(proxy ScrapedItem, items chan<- SaveableItem) {})).
+		RegisterStage("keep", func(options ComponentOptions) (StageFunc, error) { return Keep, nil })
+
+	tests := []struct {
+		kind      string
+		available []string
+		lookup    func(name string) error
+	}{
+		{COMPONENT_EXTRACTOR, []string{"links"}, func(name string) error {
+			_, err := registry.Extractor(name, nil)
+			return err
+		}},
+		{COMPONENT_MIDDLEWARE, []string{"noop"}, func(name string) error {
+			_, err := registry.Middleware(name, nil)
+			return err
+		}},
+		{COMPONENT_RESPONSE_MIDDLEWARE, []string{"noop"}, func(name string) error {
+			_, err := registry.ResponseMiddleware(name, nil)
+			return err
+		}},
+		{COMPONENT_EXTENSION, []string{"display"}, func(name string) error {
+			_, err := registry.Extension(name, nil)
+			return err
+		}},
+		{COMPONENT_HANDLER, []string{"a", "b"}, func(name string) error {
+			_, err := registry.Handler(name, nil)
+			return err
+		}},
+		{COMPONENT_STAGE, []string{"keep"}, func(name string) error {
+			_, _, err := registry.Stage(name, nil)
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		available := registry.Available(test.kind)
+		if !reflect.DeepEqual(available, test.available) {
+			t.Errorf("Available %s: %v, expected %v", test.kind, available, test.available)
+		}
+		if err := test.lookup(available[0]); err != nil {
+			t.Errorf("Lookup of %s %s failed: %s", test.kind, available[0], err)
+		}
+		if err := test.lookup("missing"); err == nil {
+			t.Errorf("Lookup of missing %s succeeded", test.kind)
+		}
+	}
+}
+
+func TestComponentOptionsDecode(t *testing.T) {
+	options := NewComponentOptions(SitemapConfig{Urls: []string{"http://example.com/sitemap.xml"}, Since: "2020-01-01"})
+	if !reflect.DeepEqual(options["urls"], []interface{}{"http://example.com/sitemap.xml"}) || options["since"] != "2020-01-01" {
+		t.Errorf("NewComponentOptions returned %v", options)
+	}
+
+	config := SitemapConfig{}
+	if err := options.Decode(&config); err != nil || config.Since != "2020-01-01" || len(config.Urls) != 1 {
+		t.Errorf("Decode returned %+v, %v", config, err)
+	}
+	if err := (ComponentOptions{"urls": "x"}).Decode(&config); err == nil {
+		t.Error("Decode accepted string as list")
+	}
+}
+
+func TestEngineResolvesComponentsFromConfig(t *testing.T) {
+	RegisterHandler("registry-test-post", StaticHandler(func(proxy ScrapedItem, items chan<- SaveableItem) {}))
+	RegisterClosingStage("registry-test-keep", func(options ComponentOptions) (StageFunc, io.Closer, error) {
+		return Keep, nil, nil
+	})
+
+	data := `
+middleware:
+- name: randomuseragent
+extensions:
+- name: display
+pipeline:
+- stage: registry-test-keep
+scrapers:
+- name: sitemap
+  url: http://example.com
+  requestlimit: 1
+  extractor: sitemap
+  extractoroptions:
+    since: 2020-01-01
+    urls: [http://example.com/sitemap.xml]
+    patterns:
+    - type: contains
+      pattern: /blog/
+  rules:
+  - type: contains
+    pattern: /blog/
+    parse: true
+    handler: registry-test-post
+- name: api
+  url: http://example.com/api
+  requestlimit: 1
+  extractor: json
+  jsonpaths: ["$.items[*].url"]
+- name: feed
+  url: http://example.com/feed.xml
+  requestlimit: 1
+  extractor: feed
+  feed:
+    items: true
+- name: default
+  url: http://example.com
+  requestlimit: 1
+`
+	config := &ScraperConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine().FromConfig(config)
+
+	sitemap := engine.GetScraper("sitemap")
+	if extractor, ok := sitemap.extractor.(*SitemapExtractor); !ok || extractor.Since.Year() != 2020 || extractor.Patterns.Len() != 1 {
+		t.Errorf("Sitemap scraper uses %+v", sitemap.extractor)
+	}
+	if !reflect.DeepEqual(sitemap.seeds, []string{"http://example.com/sitemap.xml"}) {
+		t.Errorf("Sitemap scraper seeds %v", sitemap.seeds)
+	}
+	if extractor, ok := engine.GetScraper("api").extractor.(*JSONExtractor); !ok || len(extractor.Paths) != 1 {
+		t.Errorf("Api scraper uses %+v", engine.GetScraper("api").extractor)
+	}
+	if extractor, ok := engine.GetScraper("feed").extractor.(*FeedExtractor); !ok || !extractor.Items {
+		t.Errorf("Feed scraper uses %+v", engine.GetScraper("feed").extractor)
+	}
+	if _, ok := engine.GetScraper("default").extractor.(*LinkExtractor); !ok {
+		t.Errorf("Default scraper uses %+v", engine.GetScraper("default").extractor)
+	}
+	if engine.GetHandler("registry-test-post") == nil || len(engine.requestMiddleware) != 1 || len(engine.extensions) != 1 {
+		t.Error("Components from configuration were not attached to engine")
+	}
+
+	description := DescribeComponents(engine.Registry(), engine.Active)
+	expected := map[string][]string{
+		COMPONENT_EXTRACTOR:  {"feed", "json", "link", "sitemap"},
+		COMPONENT_MIDDLEWARE: {"randomuseragent"},
+		COMPONENT_EXTENSION:  {"display"},
+		COMPONENT_HANDLER:    {"registry-test-post"},
+		COMPONENT_STAGE:      {"registry-test-keep"},
+	}
+	for kind, active := range expected {
+		if !reflect.DeepEqual(description[kind]["active"], active) {
+			t.Errorf("Active %s: %v, expected %v", kind, description[kind]["active"], active)
+		}
+	}
+}
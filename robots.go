@@ -0,0 +1,108 @@
+package gotana
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"net/http"
+	"strings"
+)
+
+const (
+	ROBOTS_HEADER     = "X-Robots-Tag"
+	ROBOTS_USER_AGENT = "gotana"
+)
+
+type RobotsDirectives struct {
+	NoIndex  bool
+	NoFollow bool
+}
+
+func (directives RobotsDirectives) Merge(other RobotsDirectives) RobotsDirectives {
+	return RobotsDirectives{
+		NoIndex:  directives.NoIndex || other.NoIndex,
+		NoFollow: directives.NoFollow || other.NoFollow,
+	}
+}
+
+func isRobotsDirective(value string) bool {
+	switch value {
+	case "all", "none", "index", "noindex", "follow", "nofollow", "noarchive", "nosnippet", "noimageindex",
+		"notranslate", "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview", "indexifembedded":
+		return true
+	}
+	return false
+}
+
+func ParseRobotsDirectives(value string) (directives RobotsDirectives) {
+	tokens := strings.Split(strings.ToLower(strings.TrimSpace(value)), ",")
+
+	if chunks := strings.SplitN(tokens[0], ":", 2); len(chunks) == 2 {
+		agent := strings.TrimSpace(chunks[0])
+		if !isRobotsDirective(agent) {
+			if agent != ROBOTS_USER_AGENT {
+				return
+			}
+			tokens[0] = chunks[1]
+		}
+	}
+
+	for _, directive := range tokens {
+		switch strings.TrimSpace(directive) {
+		case "noindex":
+			directives.NoIndex = true
+		case "nofollow":
+			directives.NoFollow = true
+		case "none":
+			directives.NoIndex = true
+			directives.NoFollow = true
+		}
+	}
+	return
+}
+
+func ParseRobotsHeader(header http.Header) (directives RobotsDirectives) {
+	for _, value := range header[http.CanonicalHeaderKey(ROBOTS_HEADER)] {
+		directives = directives.Merge(ParseRobotsDirectives(value))
+	}
+	return
+}
+
+func ParseRobotsMeta(body []byte) (directives RobotsDirectives) {
+	page := html.NewTokenizer(bytes.NewReader(body))
+
+	for {
+		tokenType := page.Next()
+		if tokenType == html.ErrorToken {
+			return
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := page.Token()
+		switch token.Data {
+		case "body":
+			return
+		case "meta":
+			name, content := "", ""
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "name":
+					name = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if name == "robots" || name == ROBOTS_USER_AGENT {
+				directives = directives.Merge(ParseRobotsDirectives(content))
+			}
+		}
+	}
+}
+
+func PageRobotsDirectives(proxy ScrapedItem, resp *http.Response) (directives RobotsDirectives) {
+	if resp != nil {
+		directives = ParseRobotsHeader(resp.Header)
+	}
+	return directives.Merge(ParseRobotsMeta(proxy.BodyBytes))
+}
@@ -0,0 +1,101 @@
+package gotana
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected RobotsDirectives
+	}{
+		{"", RobotsDirectives{}},
+		{"all", RobotsDirectives{}},
+		{"noindex", RobotsDirectives{NoIndex: true}},
+		{" NOFOLLOW ", RobotsDirectives{NoFollow: true}},
+		{"none", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"noindex, nofollow", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"noindex,nofollow", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"noarchive, nosnippet", RobotsDirectives{}},
+		{"noindex, max-snippet:-1", RobotsDirectives{NoIndex: true}},
+		{"max-snippet:-1, nofollow", RobotsDirectives{NoFollow: true}},
+		{"max-image-preview:large, noindex", RobotsDirectives{NoIndex: true}},
+		{"unavailable_after: 25 Jun 2010 15:00:00 PST", RobotsDirectives{}},
+		{"gotana: none", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"Gotana: noindex, max-image-preview:large", RobotsDirectives{NoIndex: true}},
+		{"googlebot: noindex", RobotsDirectives{}},
+		{"bingbot: noindex, nofollow", RobotsDirectives{}},
+	}
+
+	for _, test := range tests {
+		if directives := ParseRobotsDirectives(test.value); directives != test.expected {
+			t.Errorf("ParseRobotsDirectives(%q) returned %+v, expected %+v", test.value, directives, test.expected)
+		}
+	}
+}
+
+func TestParseRobotsMeta(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected RobotsDirectives
+	}{
+		{`<html><head><meta name="ROBOTS" content="NOINDEX"></head></html>`, RobotsDirectives{NoIndex: true}},
+		{`<head><meta name="robots" content="noindex"><meta name="gotana" content="nofollow"/></head>`,
+			RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{`<head><meta name="googlebot" content="none"></head>`, RobotsDirectives{}},
+		{`<head><meta name="description" content="noindex"></head>`, RobotsDirectives{}},
+		{`<head></head><body><meta name="robots" content="nofollow"></body>`, RobotsDirectives{}},
+		{`not html at all`, RobotsDirectives{}},
+	}
+
+	for _, test := range tests {
+		if directives := ParseRobotsMeta([]byte(test.body)); directives != test.expected {
+			t.Errorf("ParseRobotsMeta(%q) returned %+v, expected %+v", test.body, directives, test.expected)
+		}
+	}
+}
+
+func TestPageRobotsDirectives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, value := range r.URL.Query()["header"] {
+			w.Header().Add(ROBOTS_HEADER, value)
+		}
+		w.Write([]byte(`<html><head><meta name="robots" content="` + r.URL.Query().Get("meta") + `"></head></html>`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		query    string
+		expected RobotsDirectives
+	}{
+		{"", RobotsDirectives{}},
+		{"header=nofollow", RobotsDirectives{NoFollow: true}},
+		{"header=googlebot:+noindex&header=gotana:+nofollow", RobotsDirectives{NoFollow: true}},
+		{"header=noindex,+nofollow", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"header=nofollow&meta=noindex", RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{"meta=max-snippet:-1,+noindex", RobotsDirectives{NoIndex: true}},
+	}
+
+	for _, test := range tests {
+		response, err := http.Get(server.URL + "/?" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if directives := PageRobotsDirectives(ScrapedItem{BodyBytes: body}, response); directives != test.expected {
+			t.Errorf("PageRobotsDirectives for %s returned %+v, expected %+v", test.query, directives, test.expected)
+		}
+	}
+
+	if directives := PageRobotsDirectives(ScrapedItem{BodyBytes: []byte(`<meta name="robots" content="none">`)}, nil); !directives.NoIndex {
+		t.Errorf("PageRobotsDirectives without response returned %+v", directives)
+	}
+}
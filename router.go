@@ -0,0 +1,62 @@
+package gotana
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	DISPATCH_FIRST = "first"
+	DISPATCH_ALL   = "all"
+)
+
+type Route struct {
+	Pattern URLPattern
+	Handler ScrapingHandlerFunc
+}
+
+type Router struct {
+	routes []Route
+	mode   string
+}
+
+func (router *Router) Handle(pattern URLPattern, handler ScrapingHandlerFunc) *Router {
+	router.routes = append(router.routes, Route{Pattern: pattern, Handler: handler})
+	return router
+}
+
+func (router *Router) SetMode(mode string) error {
+	if mode != DISPATCH_FIRST && mode != DISPATCH_ALL {
+		return errors.New(fmt.Sprintf("Unknown dispatch mode: %s", mode))
+	}
+	router.mode = mode
+	return nil
+}
+
+func (router *Router) Routes() []Route {
+	return router.routes
+}
+
+func (router *Router) Dispatch(proxy ScrapedItem, items chan<- SaveableItem) (matched bool) {
+	for index := range router.routes {
+		route := &router.routes[index]
+		ok, params := route.Pattern.Match(proxy.Url)
+		if !ok {
+			continue
+		}
+
+		matched = true
+		route.Handler(proxy.WithParams(params), items)
+		if router.mode != DISPATCH_ALL {
+			return
+		}
+	}
+	return
+}
+
+func NewRouter() (router *Router) {
+	router = &Router{
+		mode: DISPATCH_FIRST,
+	}
+	return
+}
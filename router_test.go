@@ -0,0 +1,56 @@
+package gotana
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	var handled []string
+	handler := func(name string) ScrapingHandlerFunc {
+		return func(proxy ScrapedItem, items chan<- SaveableItem) {
+			handled = append(handled, name+":"+proxy.Param("id"))
+		}
+	}
+
+	tests := []struct {
+		mode     string
+		url      string
+		expected []string
+	}{
+		{DISPATCH_FIRST, "http://example.com/issues/12", []string{"issue:12"}},
+		{DISPATCH_ALL, "http://example.com/issues/12", []string{"issue:12", "any:"}},
+		{DISPATCH_FIRST, "http://example.com/archive", []string{"any:"}},
+		{DISPATCH_ALL, "http://other.com/archive", nil},
+	}
+
+	for _, test := range tests {
+		router := NewRouter().
+			Handle(NewURLPattern(TYPE_PATH, "/issues/{id}"), handler("issue")).
+			Handle(NewURLPattern(TYPE_HOST, "example.com"), handler("any"))
+		if err := router.SetMode(test.mode); err != nil {
+			t.Fatal(err)
+		}
+
+		handled = nil
+		matched := router.Dispatch(ScrapedItem{Url: test.url}, nil)
+		if matched != (len(test.expected) > 0) || !reflect.DeepEqual(handled, test.expected) {
+			t.Errorf("Dispatch of %s in %s mode returned %v and called %v, expected %v", test.url, test.mode, matched, handled, test.expected)
+		}
+	}
+}
+
+func TestRouterSetMode(t *testing.T) {
+	router := NewRouter()
+	for _, mode := range []string{"", "al", "ALL", "last"} {
+		if err := router.SetMode(mode); err == nil {
+			t.Errorf("SetMode(%q) succeeded, expected error", mode)
+		}
+	}
+	if router.mode != DISPATCH_FIRST {
+		t.Errorf("Invalid mode changed router mode to %q", router.mode)
+	}
+	if err := router.SetMode(DISPATCH_ALL); err != nil || router.mode != DISPATCH_ALL {
+		t.Errorf("SetMode(%q) returned %v", DISPATCH_ALL, err)
+	}
+}
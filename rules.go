@@ -0,0 +1,79 @@
+package gotana
+
+import (
+	"fmt"
+)
+
+type Rule struct {
+	Pattern URLPattern
+	Follow  bool
+	Parse   bool
+	Handler string
+	Deny    bool
+}
+
+func (rule Rule) String() (result string) {
+	result = fmt.Sprintf("Rule %s. Follow: %t, parse: %t, handler: %s, deny: %t",
+		rule.Pattern, rule.Follow, rule.Parse, rule.Handler, rule.Deny)
+	return
+}
+
+func (rule Rule) Parses() bool {
+	return rule.Parse || rule.Handler != ""
+}
+
+func (rule Rule) Matches(url string) bool {
+	return rule.Pattern.Validate(url)
+}
+
+type RuleSet []Rule
+
+func (rules RuleSet) Denied(url string) bool {
+	for _, rule := range rules {
+		if rule.Deny && rule.Matches(url) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules RuleSet) ShouldFollow(url string) bool {
+	if len(rules) == 0 {
+		return true
+	}
+
+	if rules.Denied(url) {
+		return false
+	}
+
+	for _, rule := range rules {
+		if !rule.Deny && rule.Follow && rule.Matches(url) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules RuleSet) ParseRule(url string) (result Rule, ok bool) {
+	if rules.Denied(url) {
+		return
+	}
+
+	for _, rule := range rules {
+		if !rule.Deny && rule.Parses() && rule.Matches(url) {
+			return rule, true
+		}
+	}
+	return
+}
+
+func NewRule(pattern URLPattern, follow bool, parse bool, handler string, deny bool) (rule Rule) {
+	rule = Rule{
+		Pattern: pattern,
+		Follow:  follow,
+		Parse:   parse,
+		Handler: handler,
+		Deny:    deny,
+	}
+	return
+}
@@ -0,0 +1,40 @@
+package gotana
+
+import "testing"
+
+func TestRuleSet(t *testing.T) {
+	rules := RuleSet{
+		NewRule(NewURLPattern(TYPE_CONTAINS, "/list"), true, false, "", false),
+		NewRule(NewURLPattern(TYPE_PATH, "/item/{id}"), false, true, "", false),
+		NewRule(NewURLPattern(TYPE_CONTAINS, "/review/"), true, false, "reviews", false),
+		NewRule(NewURLPattern(TYPE_CONTAINS, "private"), false, false, "", true),
+	}
+
+	tests := []struct {
+		url     string
+		follow  bool
+		parse   bool
+		handler string
+	}{
+		{"https://example.com/list?page=2", true, false, ""},
+		{"https://example.com/item/1", false, true, ""},
+		{"https://example.com/review/1", true, true, "reviews"},
+		{"https://example.com/list/private", false, false, ""},
+		{"https://example.com/item/private", false, false, ""},
+		{"https://example.com/about", false, false, ""},
+	}
+
+	for _, test := range tests {
+		if follow := rules.ShouldFollow(test.url); follow != test.follow {
+			t.Errorf("ShouldFollow(%s) returned %v, expected %v", test.url, follow, test.follow)
+		}
+		rule, parse := rules.ParseRule(test.url)
+		if parse != test.parse || rule.Handler != test.handler {
+			t.Errorf("ParseRule(%s) returned %s, %v, expected parse %v with handler %q", test.url, rule, parse, test.parse, test.handler)
+		}
+	}
+
+	if !(RuleSet{}).ShouldFollow("https://example.com/about") {
+		t.Error("Empty rule set does not follow links")
+	}
+}
@@ -0,0 +1,358 @@
+package gotana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FIELD_TYPE_STRING  = "string"
+	FIELD_TYPE_NUMBER  = "number"
+	FIELD_TYPE_INTEGER = "integer"
+	FIELD_TYPE_BOOLEAN = "boolean"
+	FIELD_TYPE_ARRAY   = "array"
+	FIELD_TYPE_OBJECT  = "object"
+	FIELD_TYPE_DATE    = "date"
+)
+
+type FieldRuleConfig struct {
+	Name      string `required:"true"`
+	Required  bool
+	Type      string
+	Regex     string
+	Min       *float64
+	Max       *float64
+	MinLength *int
+	MaxLength *int
+	Enum      []string
+}
+
+type SchemaConfig struct {
+	Name     string
+	Kinds    []string
+	Fields   []FieldRuleConfig
+	Rejected string
+}
+
+type ValidationError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for index, err := range e {
+		messages[index] = err.Error()
+	}
+	return "Item is not valid. " + strings.Join(messages, "; ")
+}
+
+type FieldRule struct {
+	FieldRuleConfig
+	regex *regexp.Regexp
+}
+
+func fieldLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		return len([]rune(v)), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	}
+	return 0, false
+}
+
+func isEmptyField(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	length, ok := fieldLength(value)
+	return ok && length == 0
+}
+
+func checkType(kind string, value interface{}) bool {
+	switch kind {
+	case "":
+		return true
+	case FIELD_TYPE_STRING:
+		_, ok := value.(string)
+		return ok
+	case FIELD_TYPE_NUMBER:
+		_, ok := value.(float64)
+		return ok
+	case FIELD_TYPE_INTEGER:
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case FIELD_TYPE_BOOLEAN:
+		_, ok := value.(bool)
+		return ok
+	case FIELD_TYPE_ARRAY:
+		_, ok := value.([]interface{})
+		return ok
+	case FIELD_TYPE_OBJECT:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case FIELD_TYPE_DATE:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if _, err := time.Parse(layout, s); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (rule *FieldRule) Check(record map[string]interface{}) (errs ValidationErrors) {
+	fail := func(name string, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: rule.Name, Rule: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	value, present := record[rule.Name]
+	if !present || isEmptyField(value) {
+		if rule.Required {
+			fail("required", "is required")
+		}
+		return
+	}
+
+	if !checkType(rule.Type, value) {
+		fail("type", "expected %s, got %T", rule.Type, value)
+		return
+	}
+
+	if number, ok := value.(float64); ok {
+		if rule.Min != nil && number < *rule.Min {
+			fail("min", "%v is less than %v", number, *rule.Min)
+		}
+		if rule.Max != nil && number > *rule.Max {
+			fail("max", "%v is greater than %v", number, *rule.Max)
+		}
+	}
+
+	if length, ok := fieldLength(value); ok {
+		if rule.MinLength != nil && length < *rule.MinLength {
+			fail("minlength", "length %d is less than %d", length, *rule.MinLength)
+		}
+		if rule.MaxLength != nil && length > *rule.MaxLength {
+			fail("maxlength", "length %d is greater than %d", length, *rule.MaxLength)
+		}
+	}
+
+	if rule.regex != nil && !rule.regex.MatchString(fmt.Sprint(value)) {
+		fail("regex", "does not match %s", rule.Regex)
+	}
+
+	if len(rule.Enum) > 0 {
+		found := false
+		for _, allowed := range rule.Enum {
+			found = found || fmt.Sprint(value) == allowed
+		}
+		if !found {
+			fail("enum", "%v is not one of %s", value, strings.Join(rule.Enum, ", "))
+		}
+	}
+	return
+}
+
+func NewFieldRule(config FieldRuleConfig) (rule FieldRule, err error) {
+	rule = FieldRule{FieldRuleConfig: config}
+
+	switch config.Type {
+	case "", FIELD_TYPE_STRING, FIELD_TYPE_NUMBER, FIELD_TYPE_INTEGER, FIELD_TYPE_BOOLEAN,
+		FIELD_TYPE_ARRAY, FIELD_TYPE_OBJECT, FIELD_TYPE_DATE:
+	default:
+		err = errors.New(fmt.Sprintf("Field %s has unknown type: %s", config.Name, config.Type))
+		return
+	}
+
+	if config.Regex != "" {
+		if rule.regex, err = regexp.Compile(config.Regex); err != nil {
+			err = errors.New(fmt.Sprintf("Field %s has invalid regex: %s", config.Name, err))
+		}
+	}
+	return
+}
+
+func ItemKind(item SaveableItem) string {
+	if generic, ok := item.(GenericItem); ok {
+		return generic.Kind
+	}
+	return DescribeStruct(item)
+}
+
+type Schema struct {
+	Name   string
+	Fields []FieldRule
+	kinds  map[string]bool
+}
+
+func (schema *Schema) AddKinds(kinds ...string) *Schema {
+	for _, kind := range kinds {
+		schema.kinds[kind] = true
+	}
+	return schema
+}
+
+func (schema *Schema) AddRules(rules ...FieldRule) *Schema {
+	schema.Fields = append(schema.Fields, rules...)
+	return schema
+}
+
+func (schema *Schema) AppliesTo(item SaveableItem) bool {
+	return len(schema.kinds) == 0 || schema.kinds[ItemKind(item)]
+}
+
+func (schema *Schema) Validate(item SaveableItem) ValidationErrors {
+	data, err := item.RecordData()
+	if err != nil {
+		return ValidationErrors{{Field: "", Rule: "record", Message: err.Error()}}
+	}
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return ValidationErrors{{Field: "", Rule: "record", Message: err.Error()}}
+	}
+
+	var errs ValidationErrors
+	for index := range schema.Fields {
+		errs = append(errs, schema.Fields[index].Check(record)...)
+	}
+	return errs
+}
+
+func (schema *Schema) String() string {
+	return fmt.Sprintf("<Schema: %s>. Fields: %d", schema.Name, len(schema.Fields))
+}
+
+func NewSchema(name string) *Schema {
+	return &Schema{Name: name, kinds: make(map[string]bool)}
+}
+
+func NewSchemaFromConfig(config SchemaConfig) (schema *Schema, err error) {
+	schema = NewSchema(config.Name).AddKinds(config.Kinds...)
+	for _, fieldData := range config.Fields {
+		rule, err := NewFieldRule(fieldData)
+		if err != nil {
+			return nil, err
+		}
+		schema.AddRules(rule)
+	}
+	return
+}
+
+type RejectedSink interface {
+	Reject(item SaveableItem, errs ValidationErrors)
+}
+
+type LogRejectedSink struct {
+}
+
+func (sink LogRejectedSink) Reject(item SaveableItem, errs ValidationErrors) {
+	Logger().Warningf("Rejected %s: %s", ItemKind(item), errs)
+}
+
+type FileRejectedSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func (sink *FileRejectedSink) Reject(item SaveableItem, errs ValidationErrors) {
+	record := map[string]interface{}{
+		"kind":   ItemKind(item),
+		"errors": errs,
+	}
+	if scraper := item.Scraper(); scraper != nil {
+		record["scraper"] = scraper.Name
+	}
+	if data, err := item.RecordData(); err == nil {
+		record["item"] = json.RawMessage(data)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		Logger().Warningf("Cannot serialize rejected item: %s", err)
+		return
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.file.Write(append(line, '\n'))
+}
+
+func (sink *FileRejectedSink) Close() error {
+	return sink.file.Close()
+}
+
+func NewFileRejectedSink(path string) (sink *FileRejectedSink, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	sink = &FileRejectedSink{file: file}
+	return
+}
+
+func SchemaStage(schema *Schema, sink RejectedSink) StageFunc {
+	if sink == nil {
+		sink = LogRejectedSink{}
+	}
+	return func(item SaveableItem) ([]SaveableItem, error) {
+		if !schema.AppliesTo(item) {
+			return Keep(item)
+		}
+
+		errs := schema.Validate(item)
+		if len(errs) == 0 {
+			return Keep(item)
+		}
+
+		if scraper := item.Scraper(); scraper != nil && scraper.engine != nil {
+			scraper.engine.Meta.IncrValidationErrors(scraper, errs)
+		}
+		sink.Reject(item, errs)
+		return nil, Drop(DROP_REASON_INVALID)
+	}
+}
+
+func init() {
+	RegisterClosingStage("schema", func(options ComponentOptions) (StageFunc, io.Closer, error) {
+		config := SchemaConfig{}
+		if err := options.Decode(&config); err != nil {
+			return nil, nil, err
+		}
+		schema, err := NewSchemaFromConfig(config)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if config.Rejected == "" {
+			return SchemaStage(schema, nil), nil, nil
+		}
+		sink, err := NewFileRejectedSink(config.Rejected)
+		if err != nil {
+			return nil, nil, err
+		}
+		return SchemaStage(schema, sink), sink, nil
+	})
+}
@@ -0,0 +1,193 @@
+package gotana
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func schemaRules(t *testing.T, data string) []FieldRule {
+	var configs []FieldRuleConfig
+	if err := yaml.Unmarshal([]byte(data), &configs); err != nil {
+		t.Fatal(err)
+	}
+	rules := make([]FieldRule, len(configs))
+	for index, config := range configs {
+		rule, err := NewFieldRule(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules[index] = rule
+	}
+	return rules
+}
+
+func TestFieldRuleCheck(t *testing.T) {
+	rules := schemaRules(t, `
+- name: title
+  required: true
+  type: string
+  minlength: 3
+  maxlength: 5
+- name: price
+  type: number
+  min: 0
+  max: 100
+- name: qty
+  type: integer
+- name: currency
+  enum: [USD, EUR]
+- name: url
+  regex: ^https?://
+- name: date
+  type: date
+- name: tags
+  type: array
+  minlength: 1
+- name: active
+  type: boolean
+`)
+
+	tests := []struct {
+		name     string
+		record   string
+		expected []string
+	}{
+		{"valid", `{"title":"Book","price":3.5,"qty":2,"currency":"USD","url":"https://x","date":"2020-01-02","tags":["a"],"active":true}`, nil},
+		{"only required", `{"title":"Book"}`, nil},
+		{"empty optional", `{"title":"Book","tags":[],"url":""}`, nil},
+		{"missing required", `{"price":3}`, []string{"title:required"}},
+		{"empty required", `{"title":""}`, []string{"title:required"}},
+		{"lengths", `{"title":"Bo","tags":["a","b"]}`, []string{"title:minlength"}},
+		{"max length", `{"title":"Booklet"}`, []string{"title:maxlength"}},
+		{"bounds", `{"title":"Book","price":-1}`, []string{"price:min"}},
+		{"max", `{"title":"Book","price":101}`, []string{"price:max"}},
+		{"types", `{"title":5,"price":"12","qty":2.5,"date":"yesterday","tags":"a","active":"yes"}`,
+			[]string{"title:type", "price:type", "qty:type", "date:type", "tags:type", "active:type"}},
+		{"rfc3339 date", `{"title":"Book","date":"2020-01-02T10:00:00Z"}`, nil},
+		{"enum and regex", `{"title":"Book","currency":"PLN","url":"ftp://x"}`, []string{"currency:enum", "url:regex"}},
+	}
+
+	for _, test := range tests {
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(test.record), &record); err != nil {
+			t.Fatal(err)
+		}
+		var failures []string
+		for index := range rules {
+			for _, err := range rules[index].Check(record) {
+				failures = append(failures, err.Field+":"+err.Rule)
+			}
+		}
+		if !reflect.DeepEqual(failures, test.expected) {
+			t.Errorf("%s: Check returned %v, expected %v", test.name, failures, test.expected)
+		}
+	}
+}
+
+func TestNewFieldRuleErrors(t *testing.T) {
+	for _, config := range []FieldRuleConfig{{Name: "x", Type: "uuid"}, {Name: "x", Regex: "("}} {
+		if _, err := NewFieldRule(config); err == nil {
+			t.Errorf("NewFieldRule(%+v) succeeded, expected error", config)
+		}
+	}
+}
+
+func TestItemKind(t *testing.T) {
+	tests := []struct {
+		item     SaveableItem
+		expected string
+	}{
+		{NewGenericItem("product", ScrapedItem{}), "product"},
+		{FeedEntry{}, FEED_ITEM_KIND},
+	}
+	for _, test := range tests {
+		if kind := ItemKind(test.item); kind != test.expected {
+			t.Errorf("ItemKind of %v returned %s, expected %s", test.item, kind, test.expected)
+		}
+	}
+}
+
+func TestSchemaStageFromConfig(t *testing.T) {
+	rejected := filepath.Join(t.TempDir(), "rejected.jsonl")
+	data := `
+pipeline:
+- stage: schema
+  scrapers: [a]
+  options:
+    kinds: [product]
+    rejected: ` + rejected + `
+    fields:
+    - name: title
+      required: true
+      minlength: 3
+    - name: price
+      type: number
+      min: 0
+scrapers:
+- name: a
+  url: http://a.com
+  requestlimit: 1
+`
+	config := &ScraperConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewEngine().FromConfig(config)
+	scraper := engine.GetScraper("a")
+
+	tests := []struct {
+		kind   string
+		fields map[string]interface{}
+		kept   bool
+	}{
+		{"product", map[string]interface{}{"title": "Book", "price": 3.5}, true},
+		{"product", map[string]interface{}{"title": "Bo", "price": -1}, false},
+		{"product", map[string]interface{}{"price": "12"}, false},
+		{"review", map[string]interface{}{}, true},
+	}
+	for _, test := range tests {
+		item := NewGenericItem(test.kind, ScrapedItem{scraper: scraper})
+		for name, value := range test.fields {
+			item.Fields[name] = value
+		}
+		if kept := len(engine.Pipeline().Process(item, engine.Meta)) == 1; kept != test.kept {
+			t.Errorf("Schema kept %s %v: %v, expected %v", test.kind, test.fields, kept, test.kept)
+		}
+	}
+
+	expected := map[string]int{"title": 2, "price": 2}
+	if counts := engine.Meta.ValidationErrorCounts(scraper); !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Validation error counts %v, expected %v", counts, expected)
+	}
+
+	if err := engine.Pipeline().Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(rejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Rejected file contains %q, expected 2 lines", content)
+	}
+	record := struct {
+		Kind    string
+		Scraper string
+		Errors  []ValidationError
+		Item    map[string]interface{}
+	}{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Kind != "product" || record.Scraper != "a" || len(record.Errors) != 2 || record.Errors[0].Rule != "minlength" ||
+		record.Item["title"] != "Bo" {
+		t.Errorf("Rejected record is %+v", record)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/net/html"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -49,17 +48,6 @@ func (item ScraperMixin) Scraper() *Scraper {
 
 type ScrapingHandlerFunc func(ScrapedItem, chan<- SaveableItem)
 
-func getHref(t html.Token) (ok bool, href string) {
-	for _, a := range t.Attr {
-		if a.Key == "href" {
-			href = a.Val
-			ok = true
-		}
-	}
-
-	return
-}
-
 func trimHash(s string) string {
 	if strings.Contains(s, "#") {
 		var index int
@@ -83,60 +71,94 @@ type Extractable interface {
 	Extract(io.ReadCloser, func(string))
 }
 
-type LinkExtractor struct {
-	Extractable
+type ItemExtractable interface {
+	ExtractItems(ScrapedItem) ([]SaveableItem, error)
 }
 
-func (extractor *LinkExtractor) Extract(r io.ReadCloser, callback func(string)) {
-	page := html.NewTokenizer(r)
-	defer r.Close()
+type Seedable interface {
+	Seeds(*Scraper) []string
+}
 
-	for {
-		tokenType := page.Next()
-		if tokenType == html.ErrorToken {
-			return
-		}
-		token := page.Token()
-		if tokenType == html.StartTagToken && token.DataAtom.String() == "a" {
-			ok, url := getHref(token)
-			if !ok {
-				continue
-			}
-			url = trimHash(url)
-			callback(url)
-		}
-	}
+type LinkExtractable interface {
+	ExtractLinks(io.ReadCloser, func(Link))
 }
 
 type ScraperConfig struct {
-	Project      string `required:"true"`
-	HttpAddress  string
-	TcpAddress   string
-	RedisAddress string
-	Scrapers     []struct {
-		RequestLimit int `required:"true"`
-		Extractor    string
-		Name         string `required:"true"`
-		Url          string `required:"true"`
-		Patterns     []struct {
+	Project            string `required:"true"`
+	HttpAddress        string
+	TcpAddress         string
+	RedisAddress       string
+	Storage            string
+	Middleware         []ComponentConfig
+	ResponseMiddleware []ComponentConfig
+	Extensions         []ComponentConfig
+	Handlers           []ComponentConfig
+	Pipeline           []PipelineStageConfig
+	Scrapers           []struct {
+		RequestLimit     int `required:"true"`
+		MaxDepth         *int
+		DepthPriority    bool
+		HonorRobotsMeta  bool
+		Extractor        string
+		ExtractorOptions ComponentOptions
+		JsonPaths        []string
+		Handler          string
+		HandlerOptions   ComponentOptions
+		Name             string `required:"true"`
+		Url              string `required:"true"`
+		Patterns         []struct {
 			Type    string `required:"true"`
 			Pattern string `required:"true"`
+			Exclude bool
 		}
+		Rules []struct {
+			Type    string `required:"true"`
+			Pattern string `required:"true"`
+			Follow  bool
+			Parse   bool
+			Handler string
+			Deny    bool
+		}
+		Items      []ItemConfig
+		Pagination PaginationConfig
+		Sitemap    SitemapConfig
+		Feed       FeedConfig
 	}
 }
 
 type ScraperParams struct {
-	Name         string
-	Url          string
-	RequestLimit int
-	Extractor    Extractable
+	Name            string
+	Url             string
+	RequestLimit    int
+	MaxDepth        *int
+	DepthPriority   bool
+	HonorRobotsMeta bool
+	Extractor       Extractable
+}
+
+type ScheduledRequest struct {
+	Url   string
+	Depth int
+	Page  int
 }
 
 type ScrapedItem struct {
 	Url       string
-	FinalUrl  string   `json:"-"`
-	scraper   *Scraper `json:"-"`
-	BodyBytes []byte   `json:"-"`
+	Depth     int
+	FinalUrl  string            `json:"-"`
+	Params    map[string]string `json:"-"`
+	scraper   *Scraper          `json:"-"`
+	BodyBytes []byte            `json:"-"`
+	Robots    RobotsDirectives  `json:"-"`
+}
+
+func (proxy ScrapedItem) WithParams(params map[string]string) ScrapedItem {
+	proxy.Params = params
+	return proxy
+}
+
+func (proxy ScrapedItem) Param(name string) string {
+	return proxy.Params[name]
 }
 
 func (proxy ScrapedItem) String() (result string) {
@@ -171,20 +193,7 @@ func (proxy ScrapedItem) HTMLDocument() (document *goquery.Document, err error)
 }
 
 func (proxy ScrapedItem) CheckURLPatterns() (result bool) {
-	patterns := proxy.scraper.urlPatterns
-	if len(patterns) == 0 {
-		return true
-	}
-
-	result = false
-	for _, pattern := range patterns {
-		if pattern.Validate(proxy.Url) {
-			result = true
-			break
-		}
-	}
-
-	return
+	return proxy.scraper.urlPatterns.Validate(proxy.Url)
 }
 
 func (proxy ScrapedItem) ScheduleScraperStop() {
@@ -196,8 +205,10 @@ type Scraper struct {
 	successful   int
 	failed       int
 	handler      ScrapingHandlerFunc
+	router       *Router
 	fetchMutex   *sync.Mutex
 	crawledMutex *sync.Mutex
+	stopOnce     *sync.Once
 	Name         string
 	Domain       string
 	Scheme       string
@@ -206,10 +217,18 @@ type Scraper struct {
 	fetchedUrls  map[string]bool
 	engine       *Engine
 	extractor    Extractable
+	frontier     *Frontier
 	chDone       chan struct{}
-	chRequestUrl chan string
+	chRequestUrl chan ScheduledRequest
 	requestLimit int
-	urlPatterns  []URLPattern
+	maxDepth     int
+	urlPatterns  *URLPatternSet
+	rules        RuleSet
+	items        []*ItemDefinition
+	paginator    *Paginator
+	seeds        []string
+	linkFilters  []LinkFilter
+	honorRobots  bool
 }
 
 func (scraper *Scraper) MarkAsFetched(url string) {
@@ -257,25 +276,81 @@ func (scraper *Scraper) CheckUrl(sourceUrl string) (ok bool, url string) {
 	return
 }
 
-func (scraper *Scraper) RunExtractor(resp *http.Response) {
+func (scraper *Scraper) CheckDepth(depth int) bool {
+	return scraper.maxDepth < 0 || depth <= scraper.maxDepth
+}
+
+func (scraper *Scraper) RunExtractor(request ScheduledRequest, resp *http.Response) {
 	defer SilentRecover("EXTRACTOR")
 
-	scraper.extractor.Extract(resp.Body, func(url string) {
+	depth := request.Depth + 1
+	if !scraper.CheckDepth(depth) {
+		return
+	}
+
+	schedule := func(url string) {
 		ok, url := scraper.CheckUrl(url)
 
-		if ok {
-			scraper.chRequestUrl <- url
+		if !ok || scraper.paginator != nil && scraper.paginator.Owns(url) {
+			return
 		}
+		if scraper.rules.ShouldFollow(url) {
+			scraper.chRequestUrl <- ScheduledRequest{Url: url, Depth: depth}
+		}
+	}
+
+	if extractor, ok := scraper.extractor.(LinkExtractable); ok {
+		extractor.ExtractLinks(resp.Body, func(link Link) {
+			if scraper.AcceptsLink(link) {
+				schedule(link.Url)
+			}
+		})
+		return
+	}
+	scraper.extractor.Extract(resp.Body, schedule)
+}
+
+func (scraper *Scraper) AcceptsLink(link Link) bool {
+	for _, filter := range scraper.linkFilters {
+		if !filter(link) {
+			return false
+		}
+	}
+	return true
+}
+
+func (scraper *Scraper) Paginate(request ScheduledRequest, resp *http.Response, proxy ScrapedItem) {
+	if scraper.paginator == nil {
+		return
+	}
+
+	next, ok := scraper.paginator.Next(request, resp, proxy)
+	if !ok {
+		return
+	}
+
+	if ok, url := scraper.CheckUrl(next.Url); ok {
+		next.Url = url
+		Logger().Debugf("Scheduling page %d: %s", scraper.paginator.pageNumber(next), next.Url)
+		scraper.chRequestUrl <- next
+	}
+}
+
+func (scraper *Scraper) halt() (stopped bool) {
+	scraper.stopOnce.Do(func() {
+		Logger().Warningf("Stopping %s", scraper)
+		scraper.chDone <- struct{}{}
+		scraper.engine.wg.Done()
+		stopped = true
 	})
+	return
 }
 
 func (scraper *Scraper) Stop() {
-	Logger().Warningf("Stopping %s", scraper)
-	scraper.engine.notifyExtensions(EVENT_SCRAPER_CLOSED,
-		extensionParameters{scraper: scraper})
-
-	scraper.chDone <- struct{}{}
-	scraper.engine.wg.Done()
+	if scraper.halt() {
+		scraper.engine.notifyExtensions(EVENT_SCRAPER_CLOSED,
+			extensionParameters{scraper: scraper})
+	}
 }
 
 func (scraper *Scraper) Start() {
@@ -284,7 +359,10 @@ func (scraper *Scraper) Start() {
 	scraper.engine.notifyExtensions(EVENT_SCRAPER_OPENED,
 		extensionParameters{scraper: scraper})
 
-	scraper.chRequestUrl <- scraper.BaseUrl
+	scraper.frontier.Push(ScheduledRequest{Url: scraper.BaseUrl, Depth: 0, Page: scraper.firstPage()})
+	for _, url := range scraper.seeds {
+		scraper.frontier.Push(ScheduledRequest{Url: url, Depth: 0})
+	}
 	duration := time.Duration(scraper.requestLimit)
 
 	if scraper.requestLimit == 0 {
@@ -295,9 +373,14 @@ func (scraper *Scraper) Start() {
 
 	for {
 		select {
-		case url := <-scraper.chRequestUrl:
-			<-limiter
-			go scraper.Fetch(url)
+		case request := <-scraper.chRequestUrl:
+			if !scraper.CheckIfFetched(request.Url) {
+				scraper.frontier.Push(request)
+			}
+		case <-limiter:
+			if request, ok := scraper.frontier.Pop(); ok {
+				go scraper.Fetch(request)
+			}
 		case <-scraper.chDone:
 			Logger().Warningf("Stopped %s", scraper)
 			scraper.engine.IncrFinishedCounter()
@@ -308,12 +391,31 @@ func (scraper *Scraper) Start() {
 	return
 }
 
-func (scraper *Scraper) Notify(url string, resp *http.Response) {
+func (scraper *Scraper) firstPage() int {
+	if scraper.paginator == nil {
+		return 0
+	}
+	return 1
+}
+
+func (scraper *Scraper) Notify(request ScheduledRequest, resp *http.Response) (proxy ScrapedItem) {
+	proxy = NewScrapedItem(request.Url, scraper, resp)
+	proxy.Depth = request.Depth
+	if scraper.honorRobots {
+		proxy.Robots = PageRobotsDirectives(proxy, resp)
+	}
+	if proxy.Robots.NoIndex {
+		Logger().Debugf("Skipping noindex page %s", request.Url)
+		scraper.engine.Meta.IncrNoIndex(scraper)
+		return
+	}
 	scraper.engine.Meta.IncrScraped(scraper)
-	scraper.engine.chScraped <- NewScrapedItem(url, scraper, resp)
+	scraper.engine.chScraped <- proxy
+	return
 }
 
-func (scraper *Scraper) Fetch(url string) (resp *http.Response, err error) {
+func (scraper *Scraper) Fetch(request ScheduledRequest) (resp *http.Response, err error) {
+	url := request.Url
 	if ok := scraper.CheckIfFetched(url); ok {
 		return
 	}
@@ -329,6 +431,7 @@ func (scraper *Scraper) Fetch(url string) (resp *http.Response, err error) {
 
 	statusCode := STATUS_CODE_INITIAL
 	if err == nil {
+		resp = scraper.engine.PrepareResponse(resp)
 		statusCode = resp.StatusCode
 	}
 
@@ -341,11 +444,18 @@ func (scraper *Scraper) Fetch(url string) (resp *http.Response, err error) {
 	isSuccessful := (err == nil)
 
 	scraper.engine.Meta.UpdateRequestStats(scraper, isSuccessful, req, resp)
+	scraper.engine.Meta.IncrDepth(scraper, request.Depth)
 
 	if err == nil {
-		Logger().Debugf("Succesfully crawled %s.", url)
-		scraper.Notify(url, resp)
-		scraper.RunExtractor(resp)
+		Logger().Debugf("Succesfully crawled %s (depth %d).", url, request.Depth)
+		proxy := scraper.Notify(request, resp)
+		if proxy.Robots.NoFollow {
+			Logger().Debugf("Not following links of nofollow page %s", url)
+			scraper.engine.Meta.IncrNoFollow(scraper)
+		} else {
+			scraper.Paginate(request, resp, proxy)
+			scraper.RunExtractor(request, resp)
+		}
 	} else {
 		Logger().Warningf("Failed to crawl %s. %s", url, err)
 	}
@@ -357,15 +467,66 @@ func (scraper *Scraper) Fetch(url string) (resp *http.Response, err error) {
 }
 
 func (scraper *Scraper) AddPatterns(urlPatterns ...URLPattern) *Scraper {
-	scraper.urlPatterns = append(scraper.urlPatterns, urlPatterns...)
+	scraper.urlPatterns.Add(urlPatterns...)
+	return scraper
+}
+
+func (scraper *Scraper) AddRules(rules ...Rule) *Scraper {
+	scraper.rules = append(scraper.rules, rules...)
 	return scraper
 }
 
+func (scraper *Scraper) AddItemDefinitions(definitions ...*ItemDefinition) *Scraper {
+	scraper.items = append(scraper.items, definitions...)
+	return scraper
+}
+
+func (scraper *Scraper) SetHonorRobotsMeta(honor bool) *Scraper {
+	scraper.honorRobots = honor
+	return scraper
+}
+
+func (scraper *Scraper) AddLinkFilters(filters ...LinkFilter) *Scraper {
+	scraper.linkFilters = append(scraper.linkFilters, filters...)
+	return scraper
+}
+
+func (scraper *Scraper) AddSeeds(urls ...string) *Scraper {
+	scraper.seeds = append(scraper.seeds, urls...)
+	return scraper
+}
+
+func (scraper *Scraper) SetPaginator(paginator *Paginator) *Scraper {
+	scraper.paginator = paginator
+	return scraper
+}
+
+func (scraper *Scraper) HasRules() bool {
+	return len(scraper.rules) > 0
+}
+
+func (scraper *Scraper) Parses(url string) bool {
+	if !scraper.HasRules() {
+		return true
+	}
+	_, ok := scraper.rules.ParseRule(url)
+	return ok
+}
+
 func (scraper *Scraper) SetHandler(handler ScrapingHandlerFunc) *Scraper {
 	scraper.handler = handler
 	return scraper
 }
 
+func (scraper *Scraper) Route(pattern URLPattern, handler ScrapingHandlerFunc) *Scraper {
+	scraper.router.Handle(pattern, handler)
+	return scraper
+}
+
+func (scraper *Scraper) SetDispatchMode(mode string) error {
+	return scraper.router.SetMode(mode)
+}
+
 func (scraper *Scraper) String() (result string) {
 	stats := scraper.engine.Meta.ScraperStats[scraper.Name]
 	result = fmt.Sprintf("<Scraper: %s>. Crawled: %d, successful: %d, failed: %d. Items scraped: %d, saved: %d",
@@ -394,13 +555,22 @@ func NewScraper(params ScraperParams) (s *Scraper) {
 		Scheme:       parsed.Scheme,
 		Domain:       parsed.Host,
 		BaseUrl:      params.Url,
+		router:       NewRouter(),
+		urlPatterns:  NewURLPatternSet(),
 		fetchedUrls:  make(map[string]bool),
 		crawledMutex: &sync.Mutex{},
 		fetchMutex:   &sync.Mutex{},
+		stopOnce:     &sync.Once{},
 		extractor:    params.Extractor,
+		frontier:     NewFrontier(params.DepthPriority),
 		chDone:       make(chan struct{}),
-		chRequestUrl: make(chan string, 5),
+		chRequestUrl: make(chan ScheduledRequest, 5),
 		requestLimit: params.RequestLimit,
+		maxDepth:     -1,
+		honorRobots:  params.HonorRobotsMeta,
+	}
+	if params.MaxDepth != nil {
+		s.maxDepth = *params.MaxDepth
 	}
 	return
 }
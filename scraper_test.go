@@ -0,0 +1,90 @@
+package gotana
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func extractedRequests(scraper *Scraper, request ScheduledRequest, body string) (requests []ScheduledRequest) {
+	response := &http.Response{Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}
+	done := make(chan struct{})
+	go func() {
+		scraper.RunExtractor(request, response)
+		close(done)
+	}()
+
+	for {
+		select {
+		case request := <-scraper.chRequestUrl:
+			requests = append(requests, request)
+		case <-done:
+			for len(scraper.chRequestUrl) > 0 {
+				requests = append(requests, <-scraper.chRequestUrl)
+			}
+			return
+		}
+	}
+}
+
+func TestRunExtractorLeavesPaginatedLinksToPaginator(t *testing.T) {
+	const page = `<a href="/list?page=2">2</a><a href="/list?page=3">3</a><a href="/item/1">item</a>`
+
+	paginator, err := NewPaginator(PaginationConfig{Template: "/list?page={page}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := NewScraper(ScraperParams{Name: "example", Url: "http://example.com/list?page=1"})
+	scraper.SetPaginator(paginator)
+
+	expected := []ScheduledRequest{{Url: "http://example.com/item/1", Depth: 1}}
+	requests := extractedRequests(scraper, ScheduledRequest{Url: "http://example.com/list?page=1", Page: 1}, page)
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Extractor scheduled %+v, expected %+v", requests, expected)
+	}
+
+	scraper.SetPaginator(nil)
+	if requests = extractedRequests(scraper, ScheduledRequest{Url: "http://example.com/list"}, page); len(requests) != 3 {
+		t.Errorf("Extractor without paginator scheduled %+v, expected every link", requests)
+	}
+}
+
+func TestScraperDepthLimit(t *testing.T) {
+	const page = `<a href="/a">a</a><a href="/b">b</a>`
+
+	tests := []struct {
+		name      string
+		maxDepth  string
+		depth     int
+		scheduled int
+	}{
+		{"unset", "", 100, 2},
+		{"negative", "maxdepth: -1", 100, 2},
+		{"zero at start page", "maxdepth: 0", 0, 0},
+		{"limit not reached", "maxdepth: 2", 1, 2},
+		{"limit reached", "maxdepth: 2", 2, 0},
+	}
+
+	for _, test := range tests {
+		config := ScraperConfig{}
+		data := "scrapers:\n- name: example\n  url: http://example.com\n  " + test.maxDepth + "\n"
+		if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+			t.Fatal(err)
+		}
+		scraper := NewScraper(ScraperParams{Name: "example", Url: "http://example.com", MaxDepth: config.Scrapers[0].MaxDepth})
+
+		requests := extractedRequests(scraper, ScheduledRequest{Url: "http://example.com", Depth: test.depth}, page)
+		if len(requests) != test.scheduled {
+			t.Errorf("%s: extractor scheduled %+v, expected %d requests", test.name, requests, test.scheduled)
+		}
+		for _, request := range requests {
+			if request.Depth != test.depth+1 {
+				t.Errorf("%s: request %s scheduled at depth %d, expected %d", test.name, request.Url, request.Depth, test.depth+1)
+			}
+		}
+	}
+}
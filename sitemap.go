@@ -0,0 +1,175 @@
+package gotana
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+var sitemapDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+type SitemapConfig struct {
+	Urls     []string
+	Since    string
+	Patterns []URLPattern
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	Urls     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type SitemapExtractor struct {
+	Urls     []string
+	Since    time.Time
+	Patterns *URLPatternSet
+}
+
+func (extractor *SitemapExtractor) modifiedSince(entry sitemapEntry) bool {
+	if extractor.Since.IsZero() || entry.LastMod == "" {
+		return true
+	}
+	modified, err := ParseSitemapDate(entry.LastMod)
+	if err != nil {
+		return true
+	}
+	return !modified.Before(extractor.Since)
+}
+
+func (extractor *SitemapExtractor) acceptsPage(url string) bool {
+	return extractor.Patterns == nil || extractor.Patterns.Validate(url)
+}
+
+func (extractor *SitemapExtractor) Extract(r io.ReadCloser, callback func(string)) {
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+
+	if data, err = gunzipIfNeeded(data); err != nil {
+		Logger().Warningf("Cannot decompress sitemap: %s", err)
+		return
+	}
+
+	if isXMLDocument(data) {
+		extractor.extractSitemap(data, callback)
+		return
+	}
+
+	for _, url := range ParseRobotsSitemaps(data) {
+		callback(url)
+	}
+}
+
+func (extractor *SitemapExtractor) extractSitemap(data []byte, callback func(string)) {
+	document := sitemapDocument{}
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return
+	}
+
+	switch document.XMLName.Local {
+	case "sitemapindex":
+		for _, entry := range document.Sitemaps {
+			if extractor.modifiedSince(entry) {
+				callback(strings.TrimSpace(entry.Loc))
+			}
+		}
+	case "urlset":
+		for _, entry := range document.Urls {
+			url := strings.TrimSpace(entry.Loc)
+			if extractor.modifiedSince(entry) && extractor.acceptsPage(url) {
+				callback(url)
+			}
+		}
+	}
+}
+
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func isXMLDocument(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte("<?xml")) || bytes.HasPrefix(trimmed, []byte("<urlset")) ||
+		bytes.HasPrefix(trimmed, []byte("<sitemapindex"))
+}
+
+func ParseRobotsSitemaps(data []byte) (urls []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		chunks := strings.SplitN(line, ":", 2)
+		if len(chunks) == 2 && strings.ToLower(strings.TrimSpace(chunks[0])) == "sitemap" {
+			urls = append(urls, strings.TrimSpace(chunks[1]))
+		}
+	}
+	return
+}
+
+func ParseSitemapDate(value string) (result time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range sitemapDateLayouts {
+		if result, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("Cannot parse sitemap date: %s", value))
+	return
+}
+
+func NewSitemapExtractor(config SitemapConfig) (extractor *SitemapExtractor, err error) {
+	extractor = &SitemapExtractor{
+		Urls:     config.Urls,
+		Patterns: NewURLPatternSet(),
+	}
+
+	if config.Since != "" {
+		if extractor.Since, err = ParseSitemapDate(config.Since); err != nil {
+			return nil, err
+		}
+	}
+
+	patterns := make([]URLPattern, len(config.Patterns))
+	for index, patternData := range config.Patterns {
+		if patterns[index], err = CompileURLPattern(patternData.Type, patternData.Pattern, patternData.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	extractor.Patterns.Add(patterns...)
+	return
+}
+
+func (extractor *SitemapExtractor) Seeds(scraper *Scraper) []string {
+	if len(extractor.Urls) > 0 {
+		return extractor.Urls
+	}
+	return []string{fmt.Sprintf("%s://%s/robots.txt", scraper.Scheme, scraper.Domain)}
+}
@@ -0,0 +1,149 @@
+package gotana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sitemapUrlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc> https://example.com/blog/new </loc><lastmod>2021-05-01</lastmod></url>
+<url><loc>https://example.com/blog/old</loc><lastmod>2019-05-01T10:00:00+00:00</lastmod></url>
+<url><loc>https://example.com/blog/undated</loc></url>
+<url><loc>https://example.com/about</loc><lastmod>2021-05-01</lastmod></url>
+</urlset>`
+
+const sitemapIndex = `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>https://example.com/blog.xml.gz</loc><lastmod>2021-01-01</lastmod></sitemap>
+<sitemap><loc>https://example.com/archive.xml</loc><lastmod>2018-01-01</lastmod></sitemap>
+</sitemapindex>`
+
+func gzipped(t *testing.T, data string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestSitemapExtractor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\nSITEMAP: https://example.com/sitemap.xml\n"))
+		case "/sitemap.xml":
+			w.Write([]byte(sitemapIndex))
+		case "/blog.xml":
+			w.Write([]byte(sitemapUrlset))
+		case "/blog.xml.gz":
+			w.Write(gzipped(t, sitemapUrlset))
+		case "/page.html":
+			w.Write([]byte(`<html><a href="/blog/1">x</a></html>`))
+		}
+	}))
+	defer server.Close()
+
+	blog := []URLPattern{{Type: TYPE_CONTAINS, Pattern: "/blog/"}}
+	tests := []struct {
+		path     string
+		config   SitemapConfig
+		expected []string
+	}{
+		{"/robots.txt", SitemapConfig{}, []string{"https://example.com/sitemap.xml"}},
+		{"/sitemap.xml", SitemapConfig{}, []string{"https://example.com/blog.xml.gz", "https://example.com/archive.xml"}},
+		{"/sitemap.xml", SitemapConfig{Since: "2020-01-01"}, []string{"https://example.com/blog.xml.gz"}},
+		{"/blog.xml", SitemapConfig{}, []string{"https://example.com/blog/new", "https://example.com/blog/old",
+			"https://example.com/blog/undated", "https://example.com/about"}},
+		{"/blog.xml", SitemapConfig{Since: "2020-01-01", Patterns: blog}, []string{"https://example.com/blog/new",
+			"https://example.com/blog/undated"}},
+		{"/blog.xml.gz", SitemapConfig{Since: "2020-01-01T00:00:00Z", Patterns: blog}, []string{"https://example.com/blog/new",
+			"https://example.com/blog/undated"}},
+		{"/page.html", SitemapConfig{}, nil},
+	}
+
+	for _, test := range tests {
+		extractor, err := NewSitemapExtractor(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := http.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var urls []string
+		extractor.Extract(response.Body, func(url string) { urls = append(urls, url) })
+		if !reflect.DeepEqual(urls, test.expected) {
+			t.Errorf("Sitemap %s with %+v returned %v, expected %v", test.path, test.config, urls, test.expected)
+		}
+	}
+}
+
+func TestSitemapExtractorIgnoresBrokenInput(t *testing.T) {
+	extractor, err := NewSitemapExtractor(SitemapConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"\x1f\x8bbroken", `<?xml version="1.0"?><urlset><url>`, ""} {
+		called := false
+		extractor.Extract(ioutil.NopCloser(strings.NewReader(data)), func(url string) { called = true })
+		if called {
+			t.Errorf("Extractor returned urls for %q", data)
+		}
+	}
+}
+
+func TestParseSitemapDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"2021-05-01", time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{" 2021-05-01T10:30:00Z ", time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{"2021-05-01T10:30Z", time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{"2021-05-01T10:30:15", time.Date(2021, 5, 1, 10, 30, 15, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if result, err := ParseSitemapDate(test.value); err != nil || !result.Equal(test.expected) {
+			t.Errorf("ParseSitemapDate(%q) returned %v, %v, expected %v", test.value, result, err, test.expected)
+		}
+	}
+
+	if _, err := ParseSitemapDate("yesterday"); err == nil {
+		t.Error("ParseSitemapDate accepted invalid date")
+	}
+	if _, err := NewSitemapExtractor(SitemapConfig{Since: "yesterday"}); err == nil {
+		t.Error("NewSitemapExtractor accepted invalid since date")
+	}
+}
+
+func TestSitemapSeeds(t *testing.T) {
+	scraper := NewScraper(ScraperParams{Name: "example", Url: "https://example.com/start"})
+	tests := []struct {
+		config   SitemapConfig
+		expected []string
+	}{
+		{SitemapConfig{}, []string{"https://example.com/robots.txt"}},
+		{SitemapConfig{Urls: []string{"https://example.com/sitemap.xml"}}, []string{"https://example.com/sitemap.xml"}},
+	}
+	for _, test := range tests {
+		extractor, err := NewSitemapExtractor(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seeds := extractor.Seeds(scraper); !reflect.DeepEqual(seeds, test.expected) {
+			t.Errorf("Seeds returned %v, expected %v", seeds, test.expected)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package gotana
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	URL "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	STORAGE_LOCAL     = "local"
+	STORAGE_S3        = "s3"
+	S3_DEFAULT_REGION = "us-east-1"
+	S3_ALGORITHM      = "AWS4-HMAC-SHA256"
+	S3_AMZ_DATE       = "20060102T150405Z"
+	S3_UPLOAD_TIMEOUT = time.Duration(time.Minute * 5)
+	S3_UPLOAD_RETRIES = 3
+)
+
+type ExportStorage interface {
+	TempFile(path string) (*os.File, error)
+	Store(temp string, path string) (string, error)
+}
+
+type ExportStorageConfig struct {
+	Type      string
+	Endpoint  string
+	Bucket    string
+	Region    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Timeout   string
+}
+
+type LocalStorage struct {
+}
+
+func (storage LocalStorage) TempFile(path string) (*os.File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dir, "."+filepath.Base(path)+".*.tmp")
+}
+
+func (storage LocalStorage) Store(temp string, path string) (string, error) {
+	if err := os.Chmod(temp, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (storage LocalStorage) String() string {
+	return "LocalStorage"
+}
+
+type S3Storage struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Retries   int
+	client    *http.Client
+	backoff   time.Duration
+}
+
+func (storage *S3Storage) TempFile(path string) (*os.File, error) {
+	return ioutil.TempFile("", "gotana-export-*")
+}
+
+func (storage *S3Storage) Key(path string) string {
+	key := strings.TrimLeft(filepath.ToSlash(filepath.Clean(path)), "/")
+	if storage.Prefix != "" {
+		key = strings.TrimRight(storage.Prefix, "/") + "/" + key
+	}
+	return key
+}
+
+func s3Escape(path string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(path) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (storage *S3Storage) Sign(request *http.Request, payloadHash string, at time.Time) {
+	at = at.UTC()
+	amzDate := at.Format(S3_AMZ_DATE)
+	date := at.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3Escape(request.URL.Path),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, storage.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		S3_ALGORITHM,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+storage.SecretKey), date)
+	key = hmacSHA256(key, storage.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		S3_ALGORITHM, storage.AccessKey, scope, signedHeaders, signature))
+}
+
+func (storage *S3Storage) Put(key string, body io.ReadSeeker, size int64) (location string, err error) {
+	if _, err = body.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(hash, body); err != nil {
+		return
+	}
+	if _, err = body.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	location = strings.TrimRight(storage.Endpoint, "/") + "/" + storage.Bucket + "/" + s3Escape(key)
+	request, err := http.NewRequest(http.MethodPut, location, ioutil.NopCloser(body))
+	if err != nil {
+		return
+	}
+	request.ContentLength = size
+	request.URL.RawPath = s3Escape(request.URL.Path)
+	storage.Sign(request, hex.EncodeToString(hash.Sum(nil)), time.Now())
+
+	response, err := storage.client.Do(request)
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		err = errors.New(fmt.Sprintf("Cannot upload %s: %s %s", key, response.Status, strings.TrimSpace(string(message))))
+	}
+	return
+}
+
+func (storage *S3Storage) upload(temp string, path string) (location string, err error) {
+	file, err := os.Open(temp)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		if location, err = storage.Put(storage.Key(path), file, info.Size()); err == nil || attempt >= storage.Retries {
+			return
+		}
+		Logger().Warningf("Upload of %s failed (attempt %d of %d): %s", path, attempt, storage.Retries, err)
+		time.Sleep(storage.backoff * time.Duration(attempt))
+	}
+}
+
+func (storage *S3Storage) Store(temp string, path string) (location string, err error) {
+	if location, err = storage.upload(temp, path); err != nil {
+		return "", errors.New(fmt.Sprintf("%s. File is kept in %s", err, temp))
+	}
+	os.Remove(temp)
+	return
+}
+
+func (storage *S3Storage) String() string {
+	return fmt.Sprintf("<S3Storage: %s/%s>", storage.Endpoint, storage.Bucket)
+}
+
+func NewS3Storage(config ExportStorageConfig) (storage *S3Storage, err error) {
+	if config.Bucket == "" {
+		return nil, errors.New("S3 storage requires bucket")
+	}
+	if config.Region == "" {
+		config.Region = S3_DEFAULT_REGION
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	if _, err = URL.Parse(config.Endpoint); err != nil {
+		return nil, err
+	}
+	if config.AccessKey == "" {
+		config.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if config.SecretKey == "" {
+		config.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	client := NewHTTPClient()
+	client.Timeout = S3_UPLOAD_TIMEOUT
+	if config.Timeout != "" {
+		if client.Timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid S3 upload timeout: %s", config.Timeout))
+		}
+	}
+
+	storage = &S3Storage{
+		Endpoint:  config.Endpoint,
+		Bucket:    config.Bucket,
+		Region:    config.Region,
+		Prefix:    config.Prefix,
+		AccessKey: config.AccessKey,
+		SecretKey: config.SecretKey,
+		Retries:   S3_UPLOAD_RETRIES,
+		client:    client,
+		backoff:   time.Second,
+	}
+	return
+}
+
+func NewExportStorage(config ExportStorageConfig) (ExportStorage, error) {
+	switch strings.ToLower(config.Type) {
+	case "", STORAGE_LOCAL:
+		return LocalStorage{}, nil
+	case STORAGE_S3:
+		storage, err := NewS3Storage(config)
+		if err != nil {
+			return nil, err
+		}
+		return storage, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown export storage: %s", config.Type))
+}
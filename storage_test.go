@@ -0,0 +1,158 @@
+package gotana
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type s3Stub struct {
+	mutex    sync.Mutex
+	storage  *S3Storage
+	failures int
+	objects  map[string][]byte
+	errors   []string
+}
+
+func (stub *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if stub.failures > 0 {
+		stub.failures -= 1
+		http.Error(w, "SlowDown", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		stub.errors = append(stub.errors, "unexpected method "+r.Method)
+	}
+	if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != sha256Hex(body) {
+		stub.errors = append(stub.errors, "payload hash "+hash+" does not match body")
+	}
+
+	at, err := time.Parse(S3_AMZ_DATE, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		stub.errors = append(stub.errors, "invalid date: "+err.Error())
+	}
+	check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.EscapedPath(), nil)
+	check.URL.RawPath = r.URL.EscapedPath()
+	stub.storage.Sign(check, r.Header.Get("X-Amz-Content-Sha256"), at)
+	if expected, actual := check.Header.Get("Authorization"), r.Header.Get("Authorization"); expected != actual {
+		stub.errors = append(stub.errors, "signature "+actual+" does not match "+expected)
+	}
+
+	stub.objects[r.URL.EscapedPath()] = body
+}
+
+func newS3Stub(t *testing.T) (*s3Stub, *httptest.Server) {
+	stub := &s3Stub{objects: make(map[string][]byte)}
+	server := httptest.NewServer(stub)
+
+	storage, err := NewS3Storage(ExportStorageConfig{
+		Endpoint:  server.URL,
+		Bucket:    "exports",
+		Prefix:    "gotana/",
+		AccessKey: "AKID",
+		SecretKey: "SECRET",
+		Timeout:   "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.backoff = 0
+	stub.storage = storage
+	return stub, server
+}
+
+func TestS3StoragePut(t *testing.T) {
+	stub, server := newS3Stub(t)
+	defer server.Close()
+
+	data := []byte(`{"title":"item"}`)
+	location, err := stub.storage.Put(stub.storage.Key("project/items 1.jsonl"), bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := server.URL + "/exports/gotana/project/items%201.jsonl"; location != expected {
+		t.Errorf("Put returned location %s, expected %s", location, expected)
+	}
+	if object := stub.objects["/exports/gotana/project/items%201.jsonl"]; !bytes.Equal(object, data) {
+		t.Errorf("Stored object is %q, expected %q", object, data)
+	}
+	for _, message := range stub.errors {
+		t.Error(message)
+	}
+}
+
+func TestS3StorageSign(t *testing.T) {
+	storage := &S3Storage{Region: "eu-west-1", AccessKey: "AKID", SecretKey: "SECRET"}
+	at := time.Date(2017, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	sign := func(path string, contentType string) string {
+		request, _ := http.NewRequest(http.MethodPut, "http://localhost:9000"+path, nil)
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		storage.Sign(request, sha256Hex(nil), at)
+		return request.Header.Get("Authorization")
+	}
+
+	authorization := sign("/exports/a.csv", "")
+	prefix := "AWS4-HMAC-SHA256 Credential=AKID/20170501/eu-west-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(authorization, prefix) {
+		t.Fatalf("Authorization %s does not start with %s", authorization, prefix)
+	}
+	if authorization != sign("/exports/a.csv", "") {
+		t.Error("Signature is not deterministic")
+	}
+	if authorization == sign("/exports/b.csv", "") {
+		t.Error("Signature does not depend on path")
+	}
+	if withType := sign("/exports/a.csv", "text/csv"); !strings.Contains(withType, "SignedHeaders=content-type;host;") {
+		t.Errorf("Content type is not signed: %s", withType)
+	}
+}
+
+func TestS3StorageStore(t *testing.T) {
+	stub, server := newS3Stub(t)
+	defer server.Close()
+
+	temp, err := stub.storage.TempFile("items.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp.WriteString("{}\n")
+	temp.Close()
+
+	stub.failures = stub.storage.Retries
+	if _, err = stub.storage.Store(temp.Name(), "items.jsonl"); err == nil || !strings.Contains(err.Error(), temp.Name()) {
+		t.Fatalf("Store returned %v, expected error with path of kept file", err)
+	}
+	if _, err = os.Stat(temp.Name()); err != nil {
+		t.Fatalf("File of failed upload was removed: %s", err)
+	}
+
+	stub.failures = stub.storage.Retries - 1
+	if _, err = stub.storage.Store(temp.Name(), "items.jsonl"); err != nil {
+		t.Fatalf("Store failed after retries: %s", err)
+	}
+	if _, err = os.Stat(temp.Name()); !os.IsNotExist(err) {
+		os.Remove(temp.Name())
+		t.Error("File of uploaded export was not removed")
+	}
+	if object := string(stub.objects["/exports/gotana/items.jsonl"]); object != "{}\n" {
+		t.Errorf("Stored object is %q", object)
+	}
+	for _, message := range stub.errors {
+		t.Error(message)
+	}
+}
@@ -136,6 +136,10 @@ func CommandStats(message string, conn net.Conn, server *TCPServer) {
 	for _, scraper := range server.engine.scrapers {
 		writeLine(conn, scraper.String())
 		writeLine(conn, fmt.Sprintf("Currently fetching: %s", scraper.CurrentUrl))
+		for name, stats := range server.engine.Meta.PipelineStats(scraper) {
+			writeLine(conn, fmt.Sprintf("Stage %s. Processed: %d, emitted: %d, dropped: %d, errors: %d",
+				name, stats.Processed, stats.Emitted, stats.Dropped, stats.Errors))
+		}
 	}
 }
 
@@ -173,6 +177,16 @@ func CommandMiddleware(message string, conn net.Conn, server *TCPServer) {
 	}
 }
 
+func CommandComponents(message string, conn net.Conn, server *TCPServer) {
+	components := DescribeComponents(server.engine.Registry(), server.engine.Active)
+
+	for _, kind := range componentKinds {
+		writeLine(conn, fmt.Sprintf("%s. Available: %s. Active: %s", kind,
+			strings.Join(components[kind]["available"], ", "),
+			strings.Join(components[kind]["active"], ", ")))
+	}
+}
+
 func CommandItems(message string, conn net.Conn, server *TCPServer) {
 
 }
@@ -192,6 +206,7 @@ func NewTCPServer(address string, engine *Engine) (server *TCPServer) {
 	server.AddCommand("EXTENSIONS", CommandExtensions)
 	server.AddCommand("MIDDLEWARE", CommandMiddleware)
 	server.AddCommand("ITEMS", CommandItems)
+	server.AddCommand("COMPONENTS", CommandComponents)
 
 	return
 }
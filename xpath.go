@@ -0,0 +1,216 @@
+package gotana
+
+import (
+	"bytes"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+	"strings"
+	"sync"
+)
+
+const XPATH_CACHE_SIZE = 1024
+
+var xpathCacheMutex = &sync.RWMutex{}
+var xpathCache = make(map[string]*xpath.Expr)
+
+func CompileXPath(expression string) (*xpath.Expr, error) {
+	return xpath.Compile(expression)
+}
+
+// Cached expressions are shared between goroutines, so they may only be used
+// through Select, which clones the query. Evaluate mutates the expression.
+func cachedXPath(expression string) (compiled *xpath.Expr, err error) {
+	xpathCacheMutex.RLock()
+	compiled, ok := xpathCache[expression]
+	xpathCacheMutex.RUnlock()
+	if ok {
+		return
+	}
+
+	compiled, err = xpath.Compile(expression)
+	if err != nil {
+		return
+	}
+
+	xpathCacheMutex.Lock()
+	if len(xpathCache) >= XPATH_CACHE_SIZE {
+		xpathCache = make(map[string]*xpath.Expr)
+	}
+	xpathCache[expression] = compiled
+	xpathCacheMutex.Unlock()
+	return
+}
+
+type XPathNode struct {
+	htmlNode *html.Node
+	xmlNode  *xmlquery.Node
+}
+
+func (node XPathNode) navigator() xpath.NodeNavigator {
+	if node.xmlNode != nil {
+		return xmlquery.CreateXPathNavigator(node.xmlNode)
+	}
+	return htmlquery.CreateXPathNavigator(node.htmlNode)
+}
+
+func (node XPathNode) HTMLNode() *html.Node {
+	return node.htmlNode
+}
+
+func (node XPathNode) XMLNode() *xmlquery.Node {
+	return node.xmlNode
+}
+
+func (node XPathNode) Text() string {
+	if node.xmlNode != nil {
+		return node.xmlNode.InnerText()
+	}
+	return htmlquery.InnerText(node.htmlNode)
+}
+
+func (node XPathNode) Attr(name string) (value string, ok bool) {
+	if node.xmlNode != nil {
+		for _, attr := range node.xmlNode.Attr {
+			if attr.Name.Local == name {
+				return attr.Value, true
+			}
+		}
+		return
+	}
+
+	for _, attr := range node.htmlNode.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return
+}
+
+func (node XPathNode) HTML() string {
+	if node.xmlNode != nil {
+		return node.xmlNode.OutputXML(true)
+	}
+	return htmlquery.OutputHTML(node.htmlNode, true)
+}
+
+func (node XPathNode) Find(expression string) (nodes []XPathNode, err error) {
+	compiled, err := cachedXPath(expression)
+	if err != nil {
+		return
+	}
+
+	if node.xmlNode != nil {
+		for _, found := range xmlquery.QuerySelectorAll(node.xmlNode, compiled) {
+			nodes = append(nodes, XPathNode{xmlNode: found})
+		}
+		return
+	}
+
+	for _, found := range htmlquery.QuerySelectorAll(node.htmlNode, compiled) {
+		nodes = append(nodes, XPathNode{htmlNode: found})
+	}
+	return
+}
+
+func (node XPathNode) FindOne(expression string) (result XPathNode, ok bool, err error) {
+	nodes, err := node.Find(expression)
+	if err == nil && len(nodes) > 0 {
+		result, ok = nodes[0], true
+	}
+	return
+}
+
+func (node XPathNode) Texts(expression string) (values []string, err error) {
+	nodes, err := node.Find(expression)
+	for _, found := range nodes {
+		values = append(values, found.Text())
+	}
+	return
+}
+
+func (node XPathNode) Evaluate(expression string) (result interface{}, err error) {
+	compiled, err := CompileXPath(expression)
+	if err != nil {
+		return
+	}
+
+	result = compiled.Evaluate(node.navigator())
+	if iterator, ok := result.(*xpath.NodeIterator); ok {
+		var nodes []XPathNode
+		for iterator.MoveNext() {
+			nodes = append(nodes, xpathNodeFromNavigator(iterator.Current()))
+		}
+		result = nodes
+	}
+	return
+}
+
+func xpathNodeFromNavigator(navigator xpath.NodeNavigator) XPathNode {
+	switch current := navigator.(type) {
+	case *htmlquery.NodeNavigator:
+		if current.NodeType() == xpath.AttributeNode {
+			return XPathNode{htmlNode: &html.Node{Type: html.TextNode, Data: current.Value()}}
+		}
+		return XPathNode{htmlNode: current.Current()}
+	case *xmlquery.NodeNavigator:
+		if current.NodeType() == xpath.AttributeNode {
+			return XPathNode{xmlNode: &xmlquery.Node{Type: xmlquery.TextNode, Data: current.Value()}}
+		}
+		return XPathNode{xmlNode: current.Current()}
+	}
+	return XPathNode{}
+}
+
+func NewHTMLXPathNode(node *html.Node) XPathNode {
+	return XPathNode{htmlNode: node}
+}
+
+func (proxy ScrapedItem) IsXML() bool {
+	head := proxy.BodyBytes
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	lowered := strings.ToLower(string(bytes.TrimSpace(head)))
+	return strings.HasPrefix(lowered, "<?xml") && !strings.Contains(lowered, "<html")
+}
+
+func (proxy ScrapedItem) HTMLXPathDocument() (document XPathNode, err error) {
+	responseBody, err := proxy.FinalResponseBody()
+	if err != nil {
+		return
+	}
+	root, err := htmlquery.Parse(responseBody)
+	if err == nil {
+		document = XPathNode{htmlNode: root}
+	}
+	return
+}
+
+func (proxy ScrapedItem) XMLDocument() (document XPathNode, err error) {
+	responseBody, err := proxy.FinalResponseBody()
+	if err != nil {
+		return
+	}
+	root, err := xmlquery.Parse(responseBody)
+	if err == nil {
+		document = XPathNode{xmlNode: root}
+	}
+	return
+}
+
+func (proxy ScrapedItem) XPathDocument() (XPathNode, error) {
+	if proxy.IsXML() {
+		return proxy.XMLDocument()
+	}
+	return proxy.HTMLXPathDocument()
+}
+
+func (proxy ScrapedItem) XPath(expression string) (nodes []XPathNode, err error) {
+	document, err := proxy.XPathDocument()
+	if err != nil {
+		return
+	}
+	return document.Find(expression)
+}
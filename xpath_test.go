@@ -0,0 +1,187 @@
+package gotana
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const xpathPage = `<html><body><ul><li>a <b>x</b></li><li class="k">Next</li><li>after</li></ul>
+<a href="/z" title="hello world">link</a></body></html>`
+
+const xpathFeed = `<?xml version="1.0"?><urlset><url><loc>a</loc></url><url><loc lang="en">b</loc></url></urlset>`
+
+func TestXPathFind(t *testing.T) {
+	tests := []struct {
+		body       string
+		expression string
+		expected   []string
+	}{
+		{xpathPage, `//li[contains(text(),'Next')]/following-sibling::li`, []string{"after"}},
+		{xpathPage, `//a[contains(@title,'world')]/@href`, []string{"/z"}},
+		{xpathPage, `//li[@class='k']`, []string{"Next"}},
+		{xpathPage, `//li[1]`, []string{"a x"}},
+		{xpathPage, `//li/text()`, []string{"a ", "Next", "after"}},
+		{xpathPage, `//table`, nil},
+		{xpathFeed, `//loc`, []string{"a", "b"}},
+		{xpathFeed, `//loc[@lang='en']`, []string{"b"}},
+	}
+
+	for _, test := range tests {
+		proxy := ScrapedItem{BodyBytes: []byte(test.body)}
+		document, err := proxy.XPathDocument()
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts, err := document.Texts(test.expression)
+		if err != nil {
+			t.Errorf("Texts(%q) failed: %s", test.expression, err)
+			continue
+		}
+		if !reflect.DeepEqual(texts, test.expected) {
+			t.Errorf("Texts(%q) returned %q, expected %q", test.expression, texts, test.expected)
+		}
+	}
+}
+
+func TestXPathEvaluate(t *testing.T) {
+	tests := []struct {
+		body       string
+		expression string
+		expected   interface{}
+	}{
+		{xpathPage, `count(//li)`, 3.0},
+		{xpathPage, `string(//a/@title)`, "hello world"},
+		{xpathPage, `boolean(//li[@class='k'])`, true},
+		{xpathFeed, `count(//url)`, 2.0},
+		{xpathFeed, `string(//url[2]/loc)`, "b"},
+	}
+
+	for _, test := range tests {
+		document, err := ScrapedItem{BodyBytes: []byte(test.body)}.XPathDocument()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := document.Evaluate(test.expression); err != nil || result != test.expected {
+			t.Errorf("Evaluate(%q) returned %v, %v, expected %v", test.expression, result, err, test.expected)
+		}
+	}
+}
+
+func TestXPathNodeAttr(t *testing.T) {
+	for _, body := range []string{xpathPage, xpathFeed} {
+		proxy := ScrapedItem{BodyBytes: []byte(body)}
+		nodes, err := proxy.XPath(`//*[@title or @lang]`)
+		if err != nil || len(nodes) != 1 {
+			t.Fatalf("XPath returned %v, %v", nodes, err)
+		}
+		if _, ok := nodes[0].Attr("title"); ok != strings.Contains(body, "title=") {
+			t.Errorf("Attr(title) of %s returned %v", nodes[0].HTML(), ok)
+		}
+		if _, ok := nodes[0].Attr("missing"); ok {
+			t.Errorf("Attr(missing) of %s found", nodes[0].HTML())
+		}
+	}
+}
+
+func TestIsXML(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{xpathFeed, true},
+		{"  \n<?XML version=\"1.0\"?><rss></rss>", true},
+		{`<?xml version="1.0"?><html><body></body></html>`, false},
+		{xpathPage, false},
+	}
+	for _, test := range tests {
+		if isXML := (ScrapedItem{BodyBytes: []byte(test.body)}).IsXML(); isXML != test.expected {
+			t.Errorf("IsXML of %q returned %v, expected %v", test.body, isXML, test.expected)
+		}
+	}
+}
+
+func TestXPathErrors(t *testing.T) {
+	for _, expression := range []string{"//li[", "count(", "//@"} {
+		if _, err := CompileXPath(expression); err == nil {
+			t.Errorf("CompileXPath(%q) succeeded, expected error", expression)
+		}
+	}
+	if _, err := NewItemField(ItemFieldConfig{Name: "x", XPath: "//li["}); err == nil {
+		t.Error("NewItemField accepted invalid xpath")
+	}
+}
+
+func TestItemDefinitionWithXPath(t *testing.T) {
+	definition, err := NewItemDefinitionFromConfig(ItemConfig{
+		Name:           "entry",
+		ContainerXPath: "//li",
+		Fields: []ItemFieldConfig{
+			{Name: "text", XPath: "./text()", Trim: true},
+			{Name: "bold", XPath: "./b", List: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := definition.Extract(ScrapedItem{Url: "http://example.com", FinalUrl: "http://example.com", BodyBytes: []byte(xpathPage)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{
+		{"text": "a", "bold": []string{"x"}},
+		{"text": "Next", "bold": []string{}},
+		{"text": "after", "bold": []string{}},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Extracted %v, expected %d items", items, len(expected))
+	}
+	for index, item := range items {
+		if fields := item.(GenericItem).Fields; !reflect.DeepEqual(fields, expected[index]) {
+			t.Errorf("Item %d is %v, expected %v", index, fields, expected[index])
+		}
+	}
+}
+
+func TestXPathConcurrentEvaluation(t *testing.T) {
+	var wait sync.WaitGroup
+	for index := 0; index < 20; index++ {
+		wait.Add(1)
+		go func(count int) {
+			defer wait.Done()
+			body := "<ul>" + strings.Repeat("<li>x</li>", count) + "</ul>"
+			document, err := ScrapedItem{BodyBytes: []byte(body)}.HTMLXPathDocument()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for step := 0; step < 50; step++ {
+				if result, _ := document.Evaluate("count(//li)"); result != float64(count) {
+					t.Errorf("count(//li) returned %v, expected %d", result, count)
+					return
+				}
+				if nodes, _ := document.Find("//li"); len(nodes) != count {
+					t.Errorf("//li found %d nodes, expected %d", len(nodes), count)
+					return
+				}
+			}
+		}(index + 1)
+	}
+	wait.Wait()
+}
+
+func TestXPathCacheIsBounded(t *testing.T) {
+	for index := 0; index < XPATH_CACHE_SIZE+10; index++ {
+		if _, err := cachedXPath(fmt.Sprintf("//li[%d]", index)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xpathCacheMutex.RLock()
+	defer xpathCacheMutex.RUnlock()
+	if len(xpathCache) > XPATH_CACHE_SIZE {
+		t.Errorf("XPath cache holds %d expressions, limit is %d", len(xpathCache), XPATH_CACHE_SIZE)
+	}
+}